@@ -1,24 +1,228 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/ElenaMask/go_final_project/pkg/config"
 	"github.com/ElenaMask/go_final_project/pkg/db"
+	"github.com/ElenaMask/go_final_project/pkg/logging"
+	"github.com/ElenaMask/go_final_project/pkg/reminder"
 	"github.com/ElenaMask/go_final_project/pkg/server"
+	"github.com/ElenaMask/go_final_project/pkg/snapshot"
 )
 
 func main() {
-	logger := log.New(os.Stdout, "server: ", log.LstdFlags|log.Lshortfile)
+	restoreSnapshot := flag.String("restore-snapshot", "",
+		"replace the database with a snapshot before start: a name in the backup dir, a path or \"latest\"")
+	printMigrations := flag.Bool("migrations", false, "print pending database migrations and exit")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and exit")
+	cfgFlags := config.NewFlags(flag.CommandLine)
+	flag.Parse()
 
-	err := db.Init(config.DBFile)
+	cfg, err := cfgFlags.Load(os.Getenv)
 	if err != nil {
-		logger.Fatalln("error when initializing database:", err)
+		log.Fatalf("error when loading configuration: %v", err)
 	}
+	if *printConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	logger, err := logging.New(os.Stdout, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("error when configuring %s: %v", config.TODO_LOG_FORMAT, err)
+	}
+	slog.SetDefault(logger)
+
+	if *printMigrations {
+		var pending []db.Migration
+		if cfg.DBDriver == "postgres" {
+			pending, err = db.PendingPostgresMigrations(cfg.DBDSN)
+		} else {
+			pending, err = db.PendingMigrations(cfg.DBFile)
+		}
+		if err != nil {
+			fatal(logger, "error when checking migrations", "error", err)
+		}
+		if len(pending) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		for _, m := range pending {
+			fmt.Println(m.Name)
+		}
+		return
+	}
+
+	db.QueryTimeout = time.Duration(cfg.DBTimeout) * time.Second
+
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	var store db.TaskStore
+	var closeStore func() error
+	switch cfg.DBDriver {
+	case "sqlite":
+		initSQLite(ctx, &workers, cfg, logger, *restoreSnapshot)
+		store = db.SQLiteStore{}
+		closeStore = db.Close
+	case "postgres":
+		if *restoreSnapshot != "" {
+			fatal(logger, "snapshots are only supported with the sqlite driver")
+		}
+		pg, err := db.OpenPostgres(cfg.DBDSN)
+		if err != nil {
+			fatal(logger, "error when initializing database", "error", err)
+		}
+		store = pg
+		closeStore = pg.Close
+	}
+
+	srv, err := server.NewServer(cfg, logger, store)
+	if err != nil {
+		fatal(logger, "error when initializing server", "error", err)
+	}
+	servers := []*http.Server{srv.HttpServer}
+	if srv.AdminServer != nil {
+		servers = append(servers, srv.AdminServer)
+	}
+	forced := serve(logger, time.Duration(cfg.ShutdownTimeout)*time.Second, servers...)
+
+	stopWorkers()
+	workers.Wait()
+	if err := closeStore(); err != nil {
+		logger.Error("error when closing database", "error", err)
+		forced = true
+	}
+	if forced {
+		logger.Error("server stopped forcibly")
+		os.Exit(1)
+	}
+	logger.Info("server stopped")
+}
+
+// serve runs the servers until SIGINT or SIGTERM, then lets requests in
+// flight finish for up to timeout. It reports whether some of them had
+// to be cut off because the timeout ran out or a second signal came.
+func serve(logger *slog.Logger, timeout time.Duration, servers ...*http.Server) (forced bool) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() { errs <- srv.ListenAndServe() }()
+	}
+
+	select {
+	case err := <-errs:
+		fatal(logger, "server failed", "error", err)
+	case sig := <-signals:
+		logger.Info("shutting down", "signal", sig.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Warn("signal received again, stopping now", "signal", sig.String())
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("error when waiting for requests to finish", "addr", srv.Addr, "error", err)
+			srv.Close()
+			forced = true
+		}
+	}
+	return forced
+}
+
+// initSQLite opens the SQLite database and starts the workers that only
+// exist with it: reminders and scheduled snapshots. They run until ctx is
+// canceled and are tracked by workers.
+func initSQLite(ctx context.Context, workers *sync.WaitGroup, cfg *config.Config, logger *slog.Logger, restoreSnapshot string) {
+	if restoreSnapshot != "" {
+		path, err := snapshot.Restore(cfg.BackupDir, restoreSnapshot, cfg.DBFile)
+		if err != nil {
+			fatal(logger, "error when restoring snapshot", "error", err)
+		}
+		logger.Info("database restored", "path", path)
+	}
+
+	err := db.Init(cfg.DBFile)
+	if err != nil {
+		fatal(logger, "error when initializing database", "error", err)
+	}
+
+	dispatcher := &reminder.Dispatcher{
+		Notifier: newNotifier(cfg, logger),
+		Interval: time.Duration(cfg.ReminderInterval) * time.Second,
+		Now:      func() time.Time { return time.Now().In(cfg.Location) },
+		Logger:   logger,
+	}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(ctx)
+	}()
+
+	if cfg.BackupSchedule != "" {
+		schedule, err := snapshot.ParseSchedule(cfg.BackupSchedule)
+		if err != nil {
+			fatal(logger, "error when configuring "+config.TODO_BACKUP_SCHEDULE, "error", err)
+		}
+		snapshots := &snapshot.Manager{
+			Dir:    cfg.BackupDir,
+			Keep:   cfg.BackupKeep,
+			Now:    func() time.Time { return time.Now().In(cfg.Location) },
+			Logger: logger,
+		}
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			snapshots.Run(ctx, schedule)
+		}()
+	}
+}
+
+// fatal logs an error and exits, like log.Fatal does for the standard logger.
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
 
-	srv := server.NewServer(config.Port, logger, config.WebDir)
-	if err := srv.HttpServer.ListenAndServe(); err != nil {
-		logger.Fatalf("Server failed: %v", err)
+// newNotifier builds the notifier chosen in cfg; Validate has checked that
+// it is known and has the settings it needs.
+func newNotifier(cfg *config.Config, logger *slog.Logger) reminder.Notifier {
+	switch cfg.Notifier {
+	case "webhook":
+		return &reminder.WebhookNotifier{URL: cfg.WebhookURL}
+	case "smtp":
+		return &reminder.SMTPNotifier{
+			Addr:     cfg.SMTPAddr,
+			From:     cfg.SMTPFrom,
+			To:       strings.Split(cfg.SMTPTo, ","),
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+		}
+	default:
+		return &reminder.LogNotifier{Logger: logger}
 	}
 }
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+// maxAgendaOccurrences bounds the response size when many tasks repeat daily.
+// It is a variable for tests.
+var maxAgendaOccurrences = 10000
+
+const (
+	OccurrenceReal      = "real"
+	OccurrenceProjected = "projected"
+)
+
+type APIOccurrence struct {
+	APITask
+	Kind string `json:"kind"`
+}
+
+type AgendaResp struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	Occurrences []APIOccurrence `json:"occurrences"`
+	Truncated   bool            `json:"truncated,omitempty"`
+}
+
+// AgendaHandler lists every occurrence of every task between from and to.
+// The stored date of a task is a real occurrence; the following ones are
+// projected with the same rule that marking the task done would apply.
+func (a *API) AgendaHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	today := a.now()
+	if nowParam := r.FormValue("now"); nowParam != "" {
+		var err error
+		if today, err = time.Parse(DateFormat, nowParam); err != nil {
+			writeError(w, "Некорректный параметр now", http.StatusBadRequest)
+			return
+		}
+	}
+
+	from, to := today.Format(DateFormat), today.AddDate(0, 0, 6).Format(DateFormat)
+	if fromParam := r.FormValue("from"); fromParam != "" {
+		var err error
+		if from, err = parseDateParam(fromParam); err != nil {
+			writeError(w, "Некорректный параметр from", http.StatusBadRequest)
+			return
+		}
+	}
+	if toParam := r.FormValue("to"); toParam != "" {
+		var err error
+		if to, err = parseDateParam(toParam); err != nil {
+			writeError(w, "Некорректный параметр to", http.StatusBadRequest)
+			return
+		}
+	}
+
+	fromDate, _ := time.Parse(DateFormat, from)
+	toDate, _ := time.Parse(DateFormat, to)
+	if toDate.Before(fromDate) {
+		writeError(w, "Параметр from не может быть позже to", http.StatusBadRequest)
+		return
+	}
+	if days := int(toDate.Sub(fromDate).Hours()/24) + 1; days > a.cfg.AgendaMaxDays {
+		writeError(w, fmt.Sprintf("Диапазон не может быть больше %d дней", a.cfg.AgendaMaxDays), http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := a.store.AgendaTasks(r.Context(), from, to)
+	if err != nil {
+		writeDBError(w, err, "Ошибка при получении задач", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error when get agenda tasks", "error", err)
+		return
+	}
+
+	// The earliest occurrences are kept when there are too many, whatever
+	// task they belong to. The list is cut down whenever it doubles, since
+	// what falls beyond the limit then cannot come back.
+	resp := AgendaResp{From: from, To: to, Occurrences: make([]APIOccurrence, 0)}
+	keepEarliest := func() {
+		sort.SliceStable(resp.Occurrences, func(i, j int) bool {
+			return resp.Occurrences[i].Date < resp.Occurrences[j].Date
+		})
+		if len(resp.Occurrences) > maxAgendaOccurrences {
+			resp.Occurrences = resp.Occurrences[:maxAgendaOccurrences]
+			resp.Truncated = true
+		}
+	}
+	for _, task := range tasks {
+		resp.Occurrences = append(resp.Occurrences, taskOccurrences(r.Context(), task, from, to, today.Format(DateFormat))...)
+		if len(resp.Occurrences) > 2*maxAgendaOccurrences {
+			keepEarliest()
+		}
+	}
+	keepEarliest()
+
+	writeJSON(w, resp)
+}
+
+// taskOccurrences expands a task into its occurrences within [from, to].
+// Projection assumes every occurrence is done on its day, and an overdue task
+// is done today, so it continues from the later of the task date and today.
+func taskOccurrences(ctx context.Context, task *db.Task, from, to, today string) []APIOccurrence {
+	occurrence := func(date, kind string) APIOccurrence {
+		return APIOccurrence{
+			APITask: APITask{
+				ID:      strconv.FormatInt(task.ID, 10),
+				Date:    date,
+				Title:   task.Title,
+				Comment: task.Comment,
+				Repeat:  task.Repeat,
+			},
+			Kind: kind,
+		}
+	}
+
+	var result []APIOccurrence
+	if task.Date >= from && task.Date <= to {
+		result = append(result, occurrence(task.Date, OccurrenceReal))
+	}
+	if task.Repeat == "" {
+		return result
+	}
+
+	// NextDate returns the first date strictly after its now argument, so
+	// start from the day before the range to include its first day.
+	fromDate, _ := time.Parse(DateFormat, from)
+	anchor := max(task.Date, today, fromDate.AddDate(0, 0, -1).Format(DateFormat))
+
+	next, err := nextOccurrence(anchor, task.Date, task.Repeat)
+	for err == nil && next <= to {
+		result = append(result, occurrence(next, OccurrenceProjected))
+		next, err = nextOccurrence(next, next, task.Repeat)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "error when project task", "task", task.ID, "error", err)
+	}
+
+	return result
+}
+
+func nextOccurrence(now, date, repeat string) (string, error) {
+	nowDate, err := time.Parse(DateFormat, now)
+	if err != nil {
+		return "", err
+	}
+	return NextDate(nowDate, date, repeat)
+}
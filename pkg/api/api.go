@@ -0,0 +1,48 @@
+package api
+
+import (
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/config"
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+// API serves the handlers that work with tasks from the given store and the
+// web files. Handlers that only use the SQLite database may be plain
+// functions, but those that need the current time are methods, so that all
+// of them agree on it.
+type API struct {
+	store db.TaskStore
+	web   fs.FS
+	cfg   *config.Config
+	// clock is time.Now, replaced in tests.
+	clock func() time.Time
+
+	taskGauges *taskGauges
+	metrics    http.Handler
+}
+
+func New(store db.TaskStore, web fs.FS, cfg *config.Config) *API {
+	gauges := &taskGauges{}
+	return &API{
+		store:      store,
+		web:        web,
+		cfg:        cfg,
+		clock:      time.Now,
+		taskGauges: gauges,
+		metrics:    newMetricsHandler(gauges),
+	}
+}
+
+// now returns the current time in the configured time zone.
+func (a *API) now() time.Time {
+	return a.clock().In(a.cfg.Location)
+}
+
+// SQLiteOnlyHandler answers in place of the handlers that need the SQLite
+// database when tasks are stored elsewhere.
+func SQLiteOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, "Функция доступна только при хранении задач в SQLite", http.StatusNotImplemented)
+}
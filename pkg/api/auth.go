@@ -0,0 +1,106 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/logging"
+)
+
+// tokenTTL matches the lifetime of the cookie set by the web UI.
+const tokenTTL = 8 * time.Hour
+
+// owner is the user logged for requests with a valid token: there is a
+// single password, hence a single account.
+const owner = "owner"
+
+type SigninReq struct {
+	Password string `json:"password"`
+}
+
+type SigninResp struct {
+	Token string `json:"token"`
+}
+
+// SigninHandler exchanges TODO_PASSWORD for a token, which the web UI keeps
+// in the "token" cookie.
+func (a *API) SigninHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SigninReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Некорректный формат JSON", http.StatusBadRequest)
+		return
+	}
+	if a.cfg.Password == "" {
+		writeError(w, "Пароль не задан, вход не требуется", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Password), []byte(a.cfg.Password)) != 1 {
+		writeError(w, "Неверный пароль", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(w, SigninResp{Token: a.token(a.now().Add(tokenTTL))})
+}
+
+// Auth lets a request through if no password is set or if it carries a valid
+// token in the "token" cookie or in an "Authorization: Bearer" header. In
+// the latter case the request is logged as made by the owner.
+func (a *API) Auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.cfg.Password != "" {
+			if !a.validToken(requestToken(r)) {
+				writeError(w, "Требуется аутентификация", http.StatusUnauthorized)
+				return
+			}
+			logging.SetUser(r.Context(), owner)
+		}
+		next(w, r)
+	}
+}
+
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if cookie, err := r.Cookie("token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// token is the expiry time and its signature with the password, so that
+// changing the password ends every session.
+func (a *API) token(expires time.Time) string {
+	stamp := strconv.FormatInt(expires.Unix(), 10)
+	return stamp + "." + a.sign(stamp)
+}
+
+func (a *API) sign(stamp string) string {
+	mac := hmac.New(sha256.New, []byte(a.cfg.Password))
+	mac.Write([]byte(stamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (a *API) validToken(token string) bool {
+	stamp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(stamp, 10, 64)
+	if err != nil || a.now().Unix() >= expires {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(a.sign(stamp)))
+}
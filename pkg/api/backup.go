@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+const (
+	// transferTimeout bounds sending a backup and receiving one to restore,
+	// which may take longer than the server timeouts allow.
+	transferTimeout = 5 * time.Minute
+	maxRestoreSize  = 64 << 20
+)
+
+// BackupHandler sends a JSON dump of all tables.
+func (a *API) BackupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout))
+
+	now := a.now()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="scheduler-%s.json"`, now.Format(DateFormat)))
+
+	// The status is already sent once the dump is written, so an error can
+	// only be logged; the truncated JSON will then fail to restore.
+	if err := db.Backup(r.Context(), w, now); err != nil {
+		slog.ErrorContext(r.Context(), "error when write backup", "error", err)
+	}
+}
+
+// RestoreHandler restores a dump from the request body in one transaction.
+// mode=replace drops the current data first, mode=merge adds to it.
+func RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var merge bool
+	switch r.URL.Query().Get("mode") {
+	case "replace":
+	case "merge":
+		merge = true
+	default:
+		writeError(w, "Параметр mode должен быть replace или merge", http.StatusBadRequest)
+		return
+	}
+
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(transferTimeout))
+	r.Body = http.MaxBytesReader(w, r.Body, maxRestoreSize)
+
+	stats, err := db.Restore(r.Context(), r.Body, merge)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, fmt.Sprintf("Файл больше %d МБ", maxRestoreSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, db.ErrBackupFormat) {
+		writeError(w, fmt.Sprintf("Некорректный файл резервной копии: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error when restore backup", "error", err)
+		writeDBError(w, err, "Ошибка восстановления из резервной копии", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, stats)
+}
@@ -0,0 +1,244 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+	"github.com/ElenaMask/go_final_project/pkg/ical"
+)
+
+const calendarTokenSetting = "calendar_token"
+
+var icalWeekdays = [8]string{1: "MO", 2: "TU", 3: "WE", 4: "TH", 5: "FR", 6: "SA", 7: "SU"}
+
+type CalendarTokenResp struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// CalendarTokenHandler returns the secret feed URL; POST replaces the token,
+// so that previously shared URLs stop working. The server puts it behind
+// TODO_PASSWORD like the rest of the API: the token belongs to the one user
+// of the scheduler.
+func CalendarTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		token string
+		err   error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		token, err = calendarToken(r.Context())
+	case http.MethodPost:
+		token = newCalendarToken()
+		err = db.SetSetting(r.Context(), calendarTokenSetting, token)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error when get calendar token", "error", err)
+		writeDBError(w, err, "Ошибка получения токена календаря", http.StatusInternalServerError)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	writeJSON(w, CalendarTokenResp{
+		Token: token,
+		URL:   fmt.Sprintf("%s://%s/api/calendar.ics?token=%s", scheme, r.Host, url.QueryEscape(token)),
+	})
+}
+
+// CalendarHandler serves every task as an all-day VEVENT, or as a VTODO with
+// component=vtodo. The feed is protected by the token from
+// CalendarTokenHandler instead of cookies, so calendar apps can subscribe.
+// The token is only taken from the feed URL, never from a form body.
+func (a *API) CalendarHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, err := calendarToken(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error when get calendar token", "error", err)
+		writeDBError(w, err, "Ошибка получения токена календаря", http.StatusInternalServerError)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(token)) != 1 {
+		writeError(w, "Неверный токен календаря", http.StatusForbidden)
+		return
+	}
+
+	var component string
+	switch r.FormValue("component") {
+	case "", "vevent":
+		component = "VEVENT"
+	case "vtodo":
+		component = "VTODO"
+	default:
+		writeError(w, "Параметр component должен быть vevent или vtodo", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := a.store.Tasks(r.Context(), db.Cursor{}, -1)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error when get tasks for calendar", "error", err)
+		writeDBError(w, err, "Ошибка при получении задач", http.StatusInternalServerError)
+		return
+	}
+
+	now := a.now()
+	cal := ical.NewComponent("VCALENDAR")
+	cal.Add("VERSION", "2.0")
+	cal.Add("PRODID", "-//go_final_project//Scheduler//RU")
+	cal.Add("CALSCALE", "GREGORIAN")
+	cal.Add("METHOD", "PUBLISH")
+	cal.AddText("X-WR-CALNAME", "Планировщик")
+	for _, task := range tasks {
+		cal.AddComponent(a.taskComponent(r.Context(), task, component, now))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="scheduler.ics"`)
+	if err := cal.Encode(w); err != nil {
+		slog.ErrorContext(r.Context(), "error when write calendar", "error", err)
+	}
+}
+
+func calendarToken(ctx context.Context) (string, error) {
+	token, err := db.GetSetting(ctx, calendarTokenSetting)
+	if err != nil || token != "" {
+		return token, err
+	}
+	return db.InitSetting(ctx, calendarTokenSetting, newCalendarToken())
+}
+
+func newCalendarToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TaskUID is the iCalendar UID of a task; it depends only on the task id, so
+// it does not change when the task is edited or moved to its next date.
+func TaskUID(id int64) string {
+	return fmt.Sprintf("task-%d@go-final-project", id)
+}
+
+func (a *API) taskComponent(ctx context.Context, task *db.Task, name string, now time.Time) *ical.Component {
+	c := ical.NewComponent(name)
+	c.Add("UID", TaskUID(task.ID))
+	c.Add("DTSTAMP", now.UTC().Format("20060102T150405Z"))
+
+	// All-day entries end on the next day, exclusively.
+	end := task.Date
+	if date, err := time.Parse(DateFormat, task.Date); err == nil {
+		end = date.AddDate(0, 0, 1).Format(DateFormat)
+	}
+	c.Add("DTSTART", task.Date, "VALUE", "DATE")
+	if name == "VTODO" {
+		c.Add("DUE", end, "VALUE", "DATE")
+		c.Add("STATUS", "NEEDS-ACTION")
+	} else {
+		c.Add("DTEND", end, "VALUE", "DATE")
+		c.Add("TRANSP", "TRANSPARENT")
+	}
+
+	c.AddText("SUMMARY", task.Title)
+	if task.Comment != "" {
+		c.AddText("DESCRIPTION", task.Comment)
+	}
+
+	if task.Repeat == "" {
+		return c
+	}
+	if rule, ok := repeatRRule(task.Date, task.Repeat); ok {
+		c.Add("RRULE", rule)
+		return c
+	}
+
+	// Rules without an RRULE equivalent are expanded for the agenda horizon.
+	from := now.Format(DateFormat)
+	to := now.AddDate(0, 0, a.cfg.AgendaMaxDays-1).Format(DateFormat)
+	var dates []string
+	for _, o := range taskOccurrences(ctx, task, from, to, from) {
+		if o.Kind == OccurrenceProjected {
+			dates = append(dates, o.Date)
+		}
+	}
+	if len(dates) > 0 {
+		c.Add("RDATE", strings.Join(dates, ","), "VALUE", "DATE")
+	}
+	return c
+}
+
+// repeatRRule translates a repeat rule into an RRULE with the same
+// occurrences. Yearly tasks on February 29 have no equivalent: NextDate moves
+// them to March 1 in other years, while an RRULE skips those years.
+func repeatRRule(date, repeat string) (string, bool) {
+	start, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return "", false
+	}
+	if _, err := NextDate(start, date, repeat); err != nil {
+		return "", false
+	}
+
+	parts := strings.Split(repeat, " ")
+	switch parts[0] {
+	case "y":
+		if start.Month() == time.February && start.Day() == 29 {
+			return "", false
+		}
+		return "FREQ=YEARLY", true
+	case "d":
+		if parts[1] == "1" {
+			return "FREQ=DAILY", true
+		}
+		return "FREQ=DAILY;INTERVAL=" + parts[1], true
+	case "w":
+		var days []string
+		for _, n := range numberList(parts[1]) {
+			days = append(days, icalWeekdays[n])
+		}
+		return "FREQ=WEEKLY;BYDAY=" + strings.Join(days, ","), true
+	case "m":
+		rule := "FREQ=MONTHLY;BYMONTHDAY=" + joinNumbers(numberList(parts[1]))
+		if len(parts) == 3 {
+			rule += ";BYMONTH=" + joinNumbers(numberList(parts[2]))
+		}
+		return rule, true
+	}
+	return "", false
+}
+
+// numberList parses a list that NextDate has already validated.
+func numberList(s string) []int {
+	var list []int
+	for _, item := range strings.Split(s, ",") {
+		n, _ := strconv.Atoi(strings.TrimSpace(item))
+		list = append(list, n)
+	}
+	return list
+}
+
+func joinNumbers(list []int) string {
+	items := make([]string, len(list))
+	for i, n := range list {
+		items[i] = strconv.Itoa(n)
+	}
+	return strings.Join(items, ",")
+}
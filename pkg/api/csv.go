@@ -0,0 +1,289 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+// csvColumns is the column layout of the export; the import accepts the same
+// columns in any order, only title is required.
+var csvColumns = []string{"id", "date", "title", "comment", "repeat"}
+
+// utf8BOM lets spreadsheet applications detect the encoding.
+const utf8BOM = "\uFEFF"
+
+// errReadTask marks a failure to read a task being updated from the store,
+// which is not a problem of the file.
+var errReadTask = errors.New("failed to read task")
+
+type CSVRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type CSVImportResp struct {
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Error   string        `json:"error,omitempty"`
+	Rows    []CSVRowError `json:"rows,omitempty"`
+}
+
+// ExportCSVHandler writes all tasks as CSV; sep=; switches the delimiter for
+// spreadsheets in locales that use a decimal comma.
+func (a *API) ExportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sep := ','
+	switch r.FormValue("sep") {
+	case "", ",":
+	case ";":
+		sep = ';'
+	default:
+		writeError(w, "Параметр sep должен быть , или ;", http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := a.store.Tasks(r.Context(), db.Cursor{}, -1)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error when get tasks for export", "error", err)
+		writeDBError(w, err, "Ошибка при получении задач", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(utf8BOM)
+	cw := csv.NewWriter(bw)
+	cw.Comma = sep
+	cw.Write(csvColumns)
+	for _, t := range tasks {
+		cw.Write([]string{strconv.FormatInt(t.ID, 10), t.Date, csvCell(t.Title), csvCell(t.Comment), t.Repeat})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.ErrorContext(r.Context(), "error when write csv", "error", err)
+		return
+	}
+	if err := bw.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "error when write csv", "error", err)
+	}
+}
+
+// csvCell keeps spreadsheets from running a cell as a formula: text that
+// starts like one gets a leading quote, which they show as plain text. Text
+// that already starts with a quote gets one more, so that csvValue can
+// always drop exactly one.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("'=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// csvValue undoes csvCell, so that an exported file imports unchanged.
+func csvValue(s string) string {
+	return strings.TrimPrefix(s, "'")
+}
+
+// ImportCSVHandler validates every row like the task API does and saves them
+// in one transaction. If any row is invalid nothing is saved and the response
+// lists the errors by row number. With mode=upsert rows with an id update
+// that task, otherwise ids are ignored and all rows create new tasks.
+func (a *API) ImportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var upsert bool
+	switch r.URL.Query().Get("mode") {
+	case "", "insert":
+	case "upsert":
+		upsert = true
+	default:
+		writeError(w, "Параметр mode должен быть insert или upsert", http.StatusBadRequest)
+		return
+	}
+
+	body, err := importBody(w, r)
+	if err != nil {
+		writeImportError(w, err, "CSV")
+		return
+	}
+
+	tasks, rowErrors, err := a.readCSVTasks(r.Context(), body, upsert)
+	if errors.Is(err, errReadTask) {
+		slog.ErrorContext(r.Context(), "error when import csv", "error", err)
+		writeDBError(w, err, "Ошибка при получении задачи", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		writeImportError(w, err, "CSV")
+		return
+	}
+	if len(rowErrors) > 0 {
+		writeJSONStatus(w, http.StatusBadRequest, CSVImportResp{Error: "В файле есть ошибки, задачи не импортированы", Rows: rowErrors})
+		return
+	}
+
+	created, updated, err := db.SaveTasks(r.Context(), tasks)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error when import csv", "error", err)
+		writeDBError(w, err, "Ошибка импорта задач", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, CSVImportResp{Created: created, Updated: updated})
+}
+
+// readCSVTasks parses and validates the file. The delimiter is detected from
+// the header, which may start with a UTF-8 BOM.
+func (a *API) readCSVTasks(ctx context.Context, body io.Reader, upsert bool) ([]*db.Task, []CSVRowError, error) {
+	br := bufio.NewReader(body)
+	header, err := br.Peek(4096)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	if strings.HasPrefix(string(header), utf8BOM) {
+		br.Discard(len(utf8BOM))
+		header = header[len(utf8BOM):]
+	}
+	firstLine, _, _ := strings.Cut(string(header), "\n")
+
+	cr := csv.NewReader(br)
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		cr.Comma = ';'
+	}
+	cr.FieldsPerRecord = -1
+
+	names, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errors.New("файл пуст")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; ok {
+			return nil, nil, fmt.Errorf("столбец %q повторяется", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, nil, errors.New("нет столбца title")
+	}
+
+	var (
+		tasks     []*db.Task
+		rowErrors []CSVRowError
+	)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrors = append(rowErrors, CSVRowError{Row: parseErr.StartLine, Error: parseErr.Err.Error()})
+				continue
+			}
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		field := func(name string) (string, bool) {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i], true
+			}
+			return "", false
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		task, err := a.csvTask(ctx, field, upsert)
+		if errors.Is(err, errReadTask) {
+			return nil, nil, err
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, CSVRowError{Row: line, Error: err.Error()})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rowErrors, nil
+}
+
+// csvTask builds a task from a row. When a row updates a task, columns
+// missing from the file keep the current values of that task.
+func (a *API) csvTask(ctx context.Context, field func(string) (string, bool), upsert bool) (*db.Task, error) {
+	task := &db.Task{}
+
+	if id, _ := field("id"); upsert && strings.TrimSpace(id) != "" {
+		id = strings.TrimSpace(id)
+		taskID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || taskID <= 0 {
+			return nil, errors.New("Некорректный идентификатор задачи")
+		}
+		current, err := a.store.GetTask(ctx, id)
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, fmt.Errorf("Задача %s не найдена", id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", errReadTask, id, err)
+		}
+		task = current
+	}
+
+	if date, ok := field("date"); ok {
+		task.Date = strings.TrimSpace(date)
+	}
+	if title, ok := field("title"); ok {
+		task.Title = csvValue(title)
+	}
+	if comment, ok := field("comment"); ok {
+		task.Comment = csvValue(comment)
+	}
+	if repeat, ok := field("repeat"); ok {
+		task.Repeat = strings.TrimSpace(repeat)
+	}
+
+	// Spreadsheets tend to reformat dates, so 02.01.2006 is accepted too.
+	if task.Date != "" {
+		date, err := parseDateParam(task.Date)
+		if err != nil {
+			return nil, fmt.Errorf("Некорректная дата %q", task.Date)
+		}
+		task.Date = date
+	}
+	if strings.TrimSpace(task.Title) == "" {
+		return nil, errors.New("Не указан заголовок задачи")
+	}
+	if utf8.RuneCountInString(task.Title) > 255 {
+		return nil, errors.New("Заголовок задачи длиннее 255 символов")
+	}
+	if err := checkDate(task, a.now()); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/ElenaMask/go_final_project/pkg/config"
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+const (
+	checkOK      = "ok"
+	checkFailed  = "failed"
+	checkUnknown = "unknown"
+)
+
+type ReadyCheck struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	Version   int     `json:"version,omitempty"`
+	Path      string  `json:"path,omitempty"`
+	FreeBytes *uint64 `json:"free_bytes,omitempty"`
+}
+
+type ReadyResp struct {
+	Status string                 `json:"status"`
+	Checks map[string]*ReadyCheck `json:"checks"`
+}
+
+// HealthzHandler answers as long as the process serves requests.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, ReadyResp{Status: checkOK, Checks: map[string]*ReadyCheck{}})
+}
+
+// ReadyzHandler checks what requests depend on: the database and its schema,
+// the web files and the free space next to the SQLite file. Any failed check
+// makes the server degraded and the answer a 503; a check that can not be
+// made on this platform is reported as unknown and does not count.
+func (a *API) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	checks := make(map[string]*ReadyCheck)
+	failed := func(err error) *ReadyCheck {
+		return &ReadyCheck{Status: checkFailed, Error: err.Error()}
+	}
+
+	if hc, ok := a.store.(db.HealthChecker); ok {
+		checks["database"] = &ReadyCheck{Status: checkOK}
+		if err := hc.Ping(r.Context()); err != nil {
+			checks["database"] = failed(err)
+		}
+		version, err := hc.SchemaVersion(r.Context())
+		checks["schema"] = &ReadyCheck{Status: checkOK, Version: version}
+		if err != nil {
+			checks["schema"] = failed(err)
+		}
+	}
+
+	checks["web"] = &ReadyCheck{Status: checkOK}
+	if f, err := a.web.Open("index.html"); err != nil {
+		checks["web"] = failed(err)
+	} else {
+		f.Close()
+	}
+
+	if _, ok := a.store.(db.SQLiteStore); ok {
+		checks["disk"] = a.diskCheck(filepath.Dir(a.cfg.DBFile))
+	}
+
+	resp := ReadyResp{Status: checkOK, Checks: checks}
+	status := http.StatusOK
+	for _, c := range checks {
+		if c.Status == checkFailed {
+			resp.Status = "degraded"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeJSONStatus(w, status, resp)
+}
+
+func (a *API) diskCheck(dir string) *ReadyCheck {
+	c := &ReadyCheck{Status: checkOK, Path: dir}
+	free, err := freeSpace(dir)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		c.Status = checkUnknown
+	case err != nil:
+		c.Status, c.Error = checkFailed, err.Error()
+	default:
+		c.FreeBytes = &free
+		if free < uint64(a.cfg.ReadyMinFreeMB)<<20 {
+			c.Status, c.Error = checkFailed, "free space is below "+config.TODO_READY_MIN_FREE_MB
+		}
+	}
+	return c
+}
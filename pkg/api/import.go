@@ -0,0 +1,404 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+	"github.com/ElenaMask/go_final_project/pkg/ical"
+)
+
+const maxImportSize = 5 << 20
+
+const (
+	ImportCreate = "create"
+	ImportUpdate = "update"
+	ImportSkip   = "skip"
+)
+
+type ImportItem struct {
+	UID    string   `json:"uid,omitempty"`
+	ID     string   `json:"id,omitempty"`
+	Action string   `json:"action"`
+	Title  string   `json:"title,omitempty"`
+	Date   string   `json:"date,omitempty"`
+	Repeat string   `json:"repeat,omitempty"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+type ImportResp struct {
+	DryRun  bool         `json:"dry_run"`
+	Created int          `json:"created"`
+	Updated int          `json:"updated"`
+	Skipped int          `json:"skipped"`
+	Items   []ImportItem `json:"items"`
+}
+
+// ImportICSHandler imports VEVENT and VTODO entries from an iCalendar file
+// sent as the request body or as the "file" field of a multipart form. Entries
+// imported before are matched by UID and updated. With dry_run=1 nothing is
+// saved and the response only reports what would be done.
+func (a *API) ImportICSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "1"
+
+	body, err := importBody(w, r)
+	if err != nil {
+		writeImportError(w, err, "iCalendar")
+		return
+	}
+	cal, err := ical.Decode(body)
+	if err != nil {
+		writeImportError(w, err, "iCalendar")
+		return
+	}
+	if cal.Name != "VCALENDAR" {
+		writeError(w, "Файл не содержит VCALENDAR", http.StatusBadRequest)
+		return
+	}
+
+	today := a.now()
+	resp := ImportResp{DryRun: dryRun, Items: make([]ImportItem, 0)}
+	var tasks []*db.ImportedTask
+	var taskItems []int
+	seen := make(map[string]bool)
+
+	for _, c := range cal.Components {
+		if c.Name != "VEVENT" && c.Name != "VTODO" {
+			continue
+		}
+		item, task := mapCalendarEntry(c, today)
+		if task != nil && item.UID != "" {
+			if seen[item.UID] {
+				item.Action = ImportSkip
+				item.Issues = append(item.Issues, "UID повторяется в файле")
+				task = nil
+			}
+			seen[item.UID] = true
+		}
+		if task != nil {
+			tasks = append(tasks, task)
+			taskItems = append(taskItems, len(resp.Items))
+		}
+		resp.Items = append(resp.Items, item)
+	}
+
+	if dryRun {
+		for _, task := range tasks {
+			if task.UID == "" {
+				task.Created = true
+				continue
+			}
+			id, err := db.TaskIDByUID(r.Context(), task.UID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "error when find imported task", "error", err)
+				writeDBError(w, err, "Ошибка при поиске задачи", http.StatusInternalServerError)
+				return
+			}
+			task.Task.ID = id
+			task.Created = id == 0
+		}
+	} else if err := db.ImportTasks(r.Context(), tasks); err != nil {
+		slog.ErrorContext(r.Context(), "error when import tasks", "error", err)
+		writeDBError(w, err, "Ошибка импорта задач", http.StatusInternalServerError)
+		return
+	}
+
+	for i, task := range tasks {
+		item := &resp.Items[taskItems[i]]
+		item.Action = ImportUpdate
+		if task.Created {
+			item.Action = ImportCreate
+		}
+		if task.Task.ID != 0 {
+			item.ID = strconv.FormatInt(task.Task.ID, 10)
+		}
+	}
+	for _, item := range resp.Items {
+		switch item.Action {
+		case ImportCreate:
+			resp.Created++
+		case ImportUpdate:
+			resp.Updated++
+		default:
+			resp.Skipped++
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+func importBody(w http.ResponseWriter, r *http.Request) (io.Reader, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func writeImportError(w http.ResponseWriter, err error, format string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, fmt.Sprintf("Файл больше %d МБ", maxImportSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+	writeError(w, fmt.Sprintf("Некорректный файл %s: %v", format, err), http.StatusBadRequest)
+}
+
+// mapCalendarEntry converts a VEVENT or VTODO into a task. The task is nil if
+// the entry is skipped; the item lists what could not be mapped exactly.
+func mapCalendarEntry(c *ical.Component, today time.Time) (ImportItem, *db.ImportedTask) {
+	item := ImportItem{UID: c.Value("UID"), Action: ImportSkip, Title: c.Text("SUMMARY")}
+	skip := func(reason string) (ImportItem, *db.ImportedTask) {
+		item.Issues = append(item.Issues, reason)
+		return item, nil
+	}
+
+	switch {
+	case c.Get("RECURRENCE-ID") != nil:
+		return skip("изменённое повторение серии не импортируется")
+	case c.Value("STATUS") == "CANCELLED":
+		return skip("запись отменена")
+	case c.Name == "VTODO" && (c.Value("STATUS") == "COMPLETED" || c.Get("COMPLETED") != nil):
+		return skip("задача уже выполнена")
+	case strings.TrimSpace(item.Title) == "":
+		return skip("нет названия SUMMARY")
+	}
+
+	if utf8.RuneCountInString(item.Title) > 255 {
+		item.Title = string([]rune(item.Title)[:255])
+		item.Issues = append(item.Issues, "название обрезано до 255 символов")
+	}
+	if item.UID == "" {
+		item.Issues = append(item.Issues, "нет UID, повторный импорт создаст задачу заново")
+	}
+
+	dateProp := c.Get("DTSTART")
+	if c.Name == "VTODO" && c.Get("DUE") != nil {
+		dateProp = c.Get("DUE")
+	}
+	var date string
+	if dateProp == nil {
+		if c.Name == "VEVENT" {
+			return skip("нет даты DTSTART")
+		}
+		item.Issues = append(item.Issues, "нет даты, задача назначена на сегодня")
+	} else {
+		var err error
+		if date, err = icalDate(dateProp, today.Location()); err != nil {
+			return skip(fmt.Sprintf("некорректная дата %q", dateProp.Value))
+		}
+	}
+
+	var repeat string
+	if rule := c.Value("RRULE"); rule != "" && date != "" {
+		var issues []string
+		repeat, issues = rruleRepeat(rule, date)
+		item.Issues = append(item.Issues, issues...)
+	}
+	if c.Get("EXDATE") != nil {
+		item.Issues = append(item.Issues, "исключения EXDATE не поддерживаются")
+	}
+	if c.Get("RDATE") != nil {
+		item.Issues = append(item.Issues, "дополнительные даты RDATE не поддерживаются")
+	}
+
+	if repeat == "" && date != "" && date < today.Format(DateFormat) {
+		if c.Name == "VEVENT" {
+			return skip("событие в прошлом")
+		}
+		item.Issues = append(item.Issues, "задача просрочена и назначена на сегодня")
+	}
+
+	task := db.Task{Date: date, Title: item.Title, Comment: c.Text("DESCRIPTION"), Repeat: repeat}
+	if err := checkDate(&task, today); err != nil {
+		return skip(err.Error())
+	}
+
+	item.Action = ImportCreate
+	item.Date, item.Repeat = task.Date, task.Repeat
+	return item, &db.ImportedTask{UID: item.UID, Task: task}
+}
+
+// icalDate returns the day of a DATE or DATE-TIME value in the time zone loc.
+// Floating times and unknown TZIDs keep the day as written.
+func icalDate(p *ical.Property, loc *time.Location) (string, error) {
+	v := p.Value
+	if len(v) == 8 {
+		t, err := time.Parse(DateFormat, v)
+		if err != nil {
+			return "", err
+		}
+		return t.Format(DateFormat), nil
+	}
+
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		if err != nil {
+			return "", err
+		}
+		return t.In(loc).Format(DateFormat), nil
+	}
+
+	tz := loc
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			tz = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, tz)
+	if err != nil {
+		return "", err
+	}
+	return t.In(loc).Format(DateFormat), nil
+}
+
+var icalWeekdayNumbers = map[string]int{"MO": 1, "TU": 2, "WE": 3, "TH": 4, "FR": 5, "SA": 6, "SU": 7}
+
+// rruleRepeat maps an RRULE onto the closest repeat rule and explains what
+// had to be changed. The result is empty if nothing close enough exists.
+func rruleRepeat(rule, date string) (string, []string) {
+	parts := make(map[string]string)
+	for _, part := range strings.Split(rule, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		parts[strings.ToUpper(name)] = strings.ToUpper(value)
+	}
+
+	var issues []string
+	issue := func(format string, args ...any) {
+		issues = append(issues, fmt.Sprintf(format, args...))
+	}
+
+	start, err := time.Parse(DateFormat, date)
+	if err != nil {
+		return "", nil
+	}
+	interval := 1
+	if n, err := strconv.Atoi(parts["INTERVAL"]); err == nil && n > 0 {
+		interval = n
+	}
+	if parts["COUNT"] != "" || parts["UNTIL"] != "" {
+		issue("окончание повторений (COUNT, UNTIL) не поддерживается, задача повторяется бессрочно")
+	}
+	for _, name := range []string{"BYSETPOS", "BYWEEKNO", "BYYEARDAY", "BYHOUR", "BYMINUTE"} {
+		if parts[name] != "" {
+			issue("%s не поддерживается и пропущен", name)
+		}
+	}
+
+	weekdays := func() []int {
+		var days []int
+		for _, d := range strings.Split(parts["BYDAY"], ",") {
+			if n, ok := icalWeekdayNumbers[d]; ok {
+				days = append(days, n)
+			} else if d != "" {
+				issue("день недели %s с номером не поддерживается", d)
+			}
+		}
+		return days
+	}
+	weekday := int(start.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+
+	var repeat string
+	switch freq := parts["FREQ"]; freq {
+	case "DAILY":
+		if parts["BYDAY"] != "" && interval == 1 {
+			repeat = "w " + joinNumbers(weekdays())
+			break
+		}
+		if parts["BYDAY"] != "" {
+			issue("дни недели (BYDAY=%s) при интервале %d дней не поддерживаются, задача будет повторяться каждые %d дней", parts["BYDAY"], interval, interval)
+		}
+		if interval > 400 {
+			issue("интервал больше 400 дней не поддерживается")
+			return "", issues
+		}
+		repeat = "d " + strconv.Itoa(interval)
+	case "WEEKLY":
+		days := weekdays()
+		if len(days) == 0 {
+			days = []int{weekday}
+		}
+		switch {
+		case interval == 1:
+			repeat = "w " + joinNumbers(days)
+		case len(days) == 1 && interval*7 <= 400:
+			repeat = "d " + strconv.Itoa(interval*7)
+		default:
+			issue("интервал %d недель не поддерживается, задача будет повторяться каждую неделю", interval)
+			repeat = "w " + joinNumbers(days)
+		}
+	case "MONTHLY":
+		var days []int
+		for _, d := range strings.Split(parts["BYMONTHDAY"], ",") {
+			n, err := strconv.Atoi(d)
+			if err == nil && (n >= 1 && n <= 31 || n == -1 || n == -2) {
+				days = append(days, n)
+			} else if d != "" {
+				issue("день месяца %s не поддерживается", d)
+			}
+		}
+		if parts["BYDAY"] != "" {
+			issue("повторение по дням недели (BYDAY=%s) не поддерживается, используется %d число", parts["BYDAY"], start.Day())
+		}
+		if len(days) == 0 {
+			days = []int{start.Day()}
+		}
+		repeat = "m " + joinNumbers(days)
+
+		months := parts["BYMONTH"]
+		if interval > 1 && months == "" {
+			if 12%interval == 0 {
+				var list []int
+				for m := int(start.Month()); len(list) < 12/interval; m += interval {
+					list = append(list, (m-1)%12+1)
+				}
+				sort.Ints(list)
+				months = joinNumbers(list)
+			} else {
+				issue("интервал %d месяцев не поддерживается, задача будет повторяться каждый месяц", interval)
+			}
+		}
+		if months != "" {
+			repeat += " " + months
+		}
+	case "YEARLY":
+		if interval > 1 {
+			issue("интервал %d лет не поддерживается, задача будет повторяться каждый год", interval)
+		}
+		if parts["BYDAY"] != "" || parts["BYMONTH"] != "" && parts["BYMONTH"] != strconv.Itoa(int(start.Month())) {
+			issue("правило %s заменено ежегодным повторением в дату задачи", rule)
+		}
+		repeat = "y"
+	case "HOURLY", "MINUTELY", "SECONDLY":
+		issue("частота %s заменена ежедневным повторением", freq)
+		repeat = "d 1"
+	default:
+		issue("неизвестная частота повторения %q", freq)
+		return "", issues
+	}
+
+	if _, err := NextDate(start, date, repeat); err != nil {
+		issue("правило %q не удалось преобразовать", rule)
+		return "", issues
+	}
+	return repeat, issues
+}
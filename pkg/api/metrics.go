@@ -0,0 +1,73 @@
+package api
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+// taskGauges reports the task counts of a store as todo_tasks. The counts are
+// read once per scrape by MetricsHandler, and the gauges return them; if the
+// read fails, the gauges are NaN and the rest is still served.
+type taskGauges struct {
+	mu     sync.Mutex
+	counts *db.TaskCounts
+}
+
+func (g *taskGauges) set(counts *db.TaskCounts) {
+	g.mu.Lock()
+	g.counts = counts
+	g.mu.Unlock()
+}
+
+func (g *taskGauges) gauge(state string, value func(*db.TaskCounts) int) prometheus.GaugeFunc {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "todo_tasks",
+		Help:        "Tasks by state: one-off tasks overdue, due today or in the future, and repeating tasks.",
+		ConstLabels: prometheus.Labels{"state": state},
+	}, func() float64 {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.counts == nil {
+			return math.NaN()
+		}
+		return float64(value(g.counts))
+	})
+}
+
+// newMetricsHandler serves the metrics of the default registry, which the
+// packages register at start, together with the task gauges of this API.
+func newMetricsHandler(gauges *taskGauges) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		gauges.gauge("overdue", func(c *db.TaskCounts) int { return c.Overdue }),
+		gauges.gauge("today", func(c *db.TaskCounts) int { return c.Today }),
+		gauges.gauge("future", func(c *db.TaskCounts) int { return c.Future }),
+		gauges.gauge("repeating", func(c *db.TaskCounts) int { return c.Repeating }),
+	)
+	return promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, registry}, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	})
+}
+
+// MetricsHandler serves the metrics for Prometheus. Task counts are read from
+// the store on every scrape.
+func (a *API) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	counts, err := a.store.CountTasksByState(r.Context(), a.now().Format(DateFormat))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error when count tasks for metrics", "error", err)
+	}
+	a.taskGauges.set(counts)
+	a.metrics.ServeHTTP(w, r)
+}
@@ -3,16 +3,19 @@ package api
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 const DateFormat = "20060102"
 
-func NextDateHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) NextDateHandler(w http.ResponseWriter, r *http.Request) {
 	nowParam := r.FormValue("now")
 	dateParam := r.FormValue("date")
 	repeatParam := r.FormValue("repeat")
@@ -23,17 +26,17 @@ func NextDateHandler(w http.ResponseWriter, r *http.Request) {
 		now, err = time.Parse(DateFormat, nowParam)
 		if err != nil {
 			http.Error(w, "Invalid now parameter format", http.StatusBadRequest)
-			log.Println("error when parse date param:", err)
+			slog.ErrorContext(r.Context(), "error when parse date param", "error", err)
 			return
 		}
 	} else {
-		now = time.Now()
+		now = a.now()
 	}
 
 	result, err := NextDate(now, dateParam, repeatParam)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		log.Println("error when calculate next task date:", err)
+		slog.ErrorContext(r.Context(), "error when calculate next task date", "error", err)
 		return
 	}
 
@@ -42,7 +45,26 @@ func NextDateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(result))
 }
 
+var nextDateErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "todo_nextdate_errors_total",
+	Help: "NextDate calls that failed, by rule type: d, w, m, y or other.",
+}, []string{"rule"})
+
 func NextDate(now time.Time, dstart string, repeat string) (string, error) {
+	next, err := nextDate(now, dstart, repeat)
+	if err != nil {
+		rule, _, _ := strings.Cut(repeat, " ")
+		switch rule {
+		case "d", "w", "m", "y":
+		default:
+			rule = "other"
+		}
+		nextDateErrors.WithLabelValues(rule).Inc()
+	}
+	return next, err
+}
+
+func nextDate(now time.Time, dstart string, repeat string) (string, error) {
 	if repeat == "" {
 		return "", nil
 	}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+type QuickTaskReq struct {
+	Text string `json:"text"`
+}
+
+type QuickTaskResp struct {
+	ID     string `json:"id,omitempty"`
+	Title  string `json:"title"`
+	Date   string `json:"date"`
+	Repeat string `json:"repeat"`
+}
+
+// QuickTaskHandler creates a task from a phrase typed into the title box.
+// With dry_run=1 it only returns the interpretation without saving it.
+func (a *API) QuickTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req QuickTaskReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Некорректный формат JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" {
+		writeError(w, "Не указан текст задачи", http.StatusBadRequest)
+		return
+	}
+
+	today := a.now()
+	if nowParam := r.FormValue("now"); nowParam != "" {
+		var err error
+		if today, err = time.Parse(DateFormat, nowParam); err != nil {
+			writeError(w, "Некорректный параметр now", http.StatusBadRequest)
+			return
+		}
+	}
+
+	parsed, err := ParseQuickTask(req.Text, today)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := QuickTaskResp{Title: parsed.Title, Date: parsed.Date, Repeat: parsed.Repeat}
+	if r.FormValue("dry_run") == "1" {
+		writeJSON(w, resp)
+		return
+	}
+
+	task := db.Task{Date: parsed.Date, Title: parsed.Title, Repeat: parsed.Repeat}
+	if err := checkDate(&task, today); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := a.store.AddTask(r.Context(), &task)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on adding task to database", "error", err)
+		writeDBError(w, err, "Ошибка добавления задачи в базу данных", http.StatusInternalServerError)
+		return
+	}
+
+	resp.ID = fmt.Sprintf("%d", id)
+	resp.Date = task.Date
+	writeJSON(w, resp)
+}
@@ -0,0 +1,692 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuickTask is the interpretation of a quick add phrase such as
+// "Pay rent every month on the 1st" or "Стендап каждый будний день".
+type QuickTask struct {
+	Title  string
+	Date   string
+	Repeat string
+}
+
+type quickToken struct {
+	orig string
+	word string
+}
+
+type quickParser struct {
+	tokens []quickToken
+	today  time.Time
+
+	date    time.Time
+	hasDate bool
+
+	// kind is one of the repeat rules d, w, m, y or zero if none was found.
+	kind  byte
+	days  int
+	items []int
+	// months is the step of a monthly rule, such as 3 for "every 3 months".
+	months int
+
+	// err is set for a phrase that is understood but cannot be used, such as
+	// "31 апреля".
+	err error
+}
+
+var (
+	everyWords = words("every", "each", "каждый", "каждую", "каждое", "каждые", "каждого", "каждой")
+	listWords  = words("and", "&", "и")
+	// datePrepositions may come before a date: "on 5.03", "в пятницу".
+	datePrepositions = words("on", "в", "во", "на")
+
+	quickWeekdays = map[string]int{
+		"monday": 1, "понедельник": 1, "пн": 1,
+		"tuesday": 2, "вторник": 2, "вт": 2,
+		"wednesday": 3, "среда": 3, "среду": 3, "ср": 3,
+		"thursday": 4, "четверг": 4, "чт": 4,
+		"friday": 5, "пятница": 5, "пятницу": 5, "пт": 5,
+		"saturday": 6, "суббота": 6, "субботу": 6, "сб": 6,
+		"sunday": 7, "воскресенье": 7, "вс": 7,
+	}
+	quickWeekdaysPlural = map[string]int{
+		"mondays": 1, "понедельникам": 1,
+		"tuesdays": 2, "вторникам": 2,
+		"wednesdays": 3, "средам": 3,
+		"thursdays": 4, "четвергам": 4,
+		"fridays": 5, "пятницам": 5,
+		"saturdays": 6, "субботам": 6,
+		"sundays": 7, "воскресеньям": 7,
+	}
+
+	quickMonths = map[string]time.Month{
+		"january": 1, "jan": 1, "января": 1, "январь": 1,
+		"february": 2, "feb": 2, "февраля": 2, "февраль": 2,
+		"march": 3, "mar": 3, "марта": 3, "март": 3,
+		"april": 4, "apr": 4, "апреля": 4, "апрель": 4,
+		"may": 5, "мая": 5, "май": 5,
+		"june": 6, "jun": 6, "июня": 6, "июнь": 6,
+		"july": 7, "jul": 7, "июля": 7, "июль": 7,
+		"august": 8, "aug": 8, "августа": 8, "август": 8,
+		"september": 9, "sep": 9, "сентября": 9, "сентябрь": 9,
+		"october": 10, "oct": 10, "октября": 10, "октябрь": 10,
+		"november": 11, "nov": 11, "ноября": 11, "ноябрь": 11,
+		"december": 12, "dec": 12, "декабря": 12, "декабрь": 12,
+	}
+
+	quickNumbers = map[string]int{
+		"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+		"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+		"один": 1, "одну": 1, "два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5,
+		"шесть": 6, "семь": 7, "восемь": 8, "девять": 9, "десять": 10,
+	}
+
+	quickUnits = map[string]string{
+		"day": "day", "days": "day", "день": "day", "дня": "day", "дней": "day",
+		"week": "week", "weeks": "week", "неделя": "week", "неделю": "week", "недели": "week", "недель": "week",
+		"month": "month", "months": "month", "месяц": "month", "месяца": "month", "месяцев": "month",
+		"year": "year", "years": "year", "год": "year", "года": "year", "лет": "year",
+	}
+)
+
+func words(list ...string) map[string]bool {
+	m := make(map[string]bool, len(list))
+	for _, w := range list {
+		m[w] = true
+	}
+	return m
+}
+
+// ParseQuickTask extracts a date and a repeat rule from a Russian or English
+// phrase; the remaining words become the title. Relative dates are resolved
+// against today. Without an explicit date a task starts today, or on the first
+// matching day for weekly and monthly rules.
+func ParseQuickTask(text string, today time.Time) (QuickTask, error) {
+	p := &quickParser{today: today}
+	for _, f := range strings.Fields(text) {
+		word := foldYo(strings.ToLower(strings.Trim(f, ".,!?;:()\"'«»")))
+		p.tokens = append(p.tokens, quickToken{orig: f, word: word})
+	}
+
+	var title []string
+	for i := 0; i < len(p.tokens); {
+		if n := p.matchRepeat(i); n > 0 {
+			i += n
+			continue
+		}
+		if n := p.matchDate(i); n > 0 {
+			i += n
+			continue
+		}
+		title = append(title, p.tokens[i].orig)
+		i++
+	}
+	if p.err != nil {
+		return QuickTask{}, p.err
+	}
+
+	task := QuickTask{Title: strings.Trim(strings.Join(title, " "), " ,;-–—")}
+	if task.Title == "" {
+		return task, errors.New("Не удалось выделить название задачи")
+	}
+
+	task.Repeat = p.repeat()
+	if err := p.checkMonthDays(); err != nil {
+		return task, err
+	}
+	switch {
+	case p.hasDate:
+		task.Date = p.date.Format(DateFormat)
+	case p.kind == 'w' || p.kind == 'm':
+		yesterday := today.AddDate(0, 0, -1)
+		next, err := NextDate(yesterday, yesterday.Format(DateFormat), task.Repeat)
+		if err != nil {
+			return task, fmt.Errorf("Некорректное правило повторения %q: %w", task.Repeat, err)
+		}
+		task.Date = next
+	default:
+		task.Date = today.Format(DateFormat)
+	}
+
+	if task.Repeat != "" {
+		if _, err := NextDate(today, task.Date, task.Repeat); err != nil {
+			return task, fmt.Errorf("Некорректное правило повторения %q: %w", task.Repeat, err)
+		}
+	}
+
+	return task, nil
+}
+
+func foldYo(s string) string {
+	return strings.ReplaceAll(s, "ё", "е")
+}
+
+func (p *quickParser) word(i int) string {
+	if i < 0 || i >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[i].word
+}
+
+func (p *quickParser) repeat() string {
+	items := make([]string, len(p.items))
+	sort.Ints(p.items)
+	for i, n := range p.items {
+		items[i] = strconv.Itoa(n)
+	}
+
+	switch p.kind {
+	case 'd':
+		return "d " + strconv.Itoa(p.days)
+	case 'w':
+		return "w " + strings.Join(items, ",")
+	case 'm':
+		if len(items) == 0 {
+			day := p.today.Day()
+			if p.hasDate {
+				day = p.date.Day()
+			}
+			items = []string{strconv.Itoa(day)}
+		}
+		if p.months > 1 {
+			months := p.monthNumbers()
+			list := make([]string, len(months))
+			for i, m := range months {
+				list[i] = strconv.Itoa(m)
+			}
+			return "m " + strings.Join(items, ",") + " " + strings.Join(list, ",")
+		}
+		return "m " + strings.Join(items, ",")
+	case 'y':
+		return "y"
+	}
+	return ""
+}
+
+// monthNumbers lists the months of a rule like "every 3 months", counting
+// from the month the task starts in.
+func (p *quickParser) monthNumbers() []int {
+	start := p.today.Month()
+	if p.hasDate {
+		start = p.date.Month()
+	}
+	months := make([]int, 0, 12/p.months)
+	for m := int(start) - 1; len(months) < 12/p.months; m += p.months {
+		months = append(months, m%12+1)
+	}
+	sort.Ints(months)
+	return months
+}
+
+// checkMonthDays rejects a rule like "every 3 months on the 31st", which
+// would silently skip April: with a step, every listed month must have the
+// day. A plain monthly rule keeps skipping short months, as "m 31" does.
+func (p *quickParser) checkMonthDays() error {
+	if p.kind != 'm' || p.months <= 1 {
+		return nil
+	}
+	day := p.today.Day()
+	if p.hasDate {
+		day = p.date.Day()
+	}
+	days := p.items
+	if len(days) == 0 {
+		days = []int{day}
+	}
+	for _, m := range p.monthNumbers() {
+		// A leap year, so that 29 February counts as possible.
+		last := time.Date(2024, time.Month(m)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for _, d := range days {
+			if d > last {
+				return fmt.Errorf("В месяце %d нет %d-го числа, повторение раз в %d мес. его пропустит; укажите другой день", m, d, p.months)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *quickParser) setRepeat(kind byte, days int, items []int) {
+	p.kind, p.days, p.items, p.months = kind, days, items, 0
+}
+
+// matchRepeat recognizes a repeat phrase at position i and returns the number
+// of tokens it takes, or zero.
+func (p *quickParser) matchRepeat(i int) int {
+	if p.kind != 0 {
+		return p.matchSecondRepeat(i)
+	}
+
+	switch w := p.word(i); {
+	case w == "daily" || w == "ежедневно":
+		p.setRepeat('d', 1, nil)
+		return 1
+	case w == "weekly" || w == "еженедельно":
+		p.setRepeat('d', 7, nil)
+		return 1
+	case w == "monthly" || w == "ежемесячно":
+		days, n := p.monthDays(i + 1)
+		p.setRepeat('m', 0, days)
+		return 1 + n
+	case w == "yearly" || w == "annually" || w == "ежегодно":
+		p.setRepeat('y', 0, nil)
+		return 1
+	case w == "через" && p.word(i+1) == "день":
+		p.setRepeat('d', 2, nil)
+		return 2
+	case everyWords[w]:
+		if n := p.matchEvery(i + 1); n > 0 {
+			return 1 + n
+		}
+	case w == "по" || w == "on":
+		switch next := p.word(i + 1); {
+		case next == "будням" || next == "weekdays":
+			p.setRepeat('w', 0, []int{1, 2, 3, 4, 5})
+			return 2
+		case next == "выходным" || next == "weekends":
+			p.setRepeat('w', 0, []int{6, 7})
+			return 2
+		case quickWeekdaysPlural[next] > 0:
+			days, n := p.weekdays(i+1, quickWeekdaysPlural)
+			p.setRepeat('w', 0, days)
+			return 1 + n
+		}
+	}
+
+	return p.matchMonthDays(i)
+}
+
+// matchSecondRepeat takes a repeat phrase after the first one, as in "Daily
+// standup every day", so that it does not end up in the title. It must say
+// the same as the first one.
+func (p *quickParser) matchSecondRepeat(i int) int {
+	second := &quickParser{tokens: p.tokens, today: p.today, date: p.date, hasDate: p.hasDate}
+	n := second.matchRepeat(i)
+	if n == 0 {
+		return 0
+	}
+	if first, other := p.repeat(), second.repeat(); first != other && p.err == nil {
+		p.err = fmt.Errorf("Указано два разных правила повторения: %q и %q", first, other)
+	}
+	if p.err == nil {
+		p.err = second.err
+	}
+	return n
+}
+
+// matchEvery handles what follows "every" or "каждый": an interval, weekdays
+// or days of the month.
+func (p *quickParser) matchEvery(j int) int {
+	w := p.word(j)
+	switch {
+	case (w == "будний" || w == "рабочий") && quickUnits[p.word(j+1)] == "day":
+		p.setRepeat('w', 0, []int{1, 2, 3, 4, 5})
+		return 2
+	case w == "weekday":
+		p.setRepeat('w', 0, []int{1, 2, 3, 4, 5})
+		return 1
+	case w == "weekend" || w == "выходные":
+		p.setRepeat('w', 0, []int{6, 7})
+		return 1
+	case quickWeekdays[w] > 0:
+		days, n := p.weekdays(j, quickWeekdays)
+		p.setRepeat('w', 0, days)
+		return n
+	}
+
+	count, n := 1, 0
+	if w == "other" {
+		count, n = 2, 1
+	} else if num, ok := quickNumber(w); ok && w != "a" && w != "an" {
+		count, n = num, 1
+	}
+
+	switch quickUnits[p.word(j+n)] {
+	case "day":
+		p.setRepeat('d', count, nil)
+		return n + 1
+	case "week":
+		p.setRepeat('d', count*7, nil)
+		return n + 1
+	case "month":
+		// The monthly rule lists months, so the step must divide the year.
+		if 12%count != 0 {
+			p.err = fmt.Errorf("Повторение раз в %d мес. не поддерживается", count)
+		}
+		days, m := p.monthDays(j + n + 1)
+		p.setRepeat('m', 0, days)
+		p.months = count
+		return n + 1 + m
+	case "year":
+		if count != 1 {
+			p.err = fmt.Errorf("Повторение раз в %d г. не поддерживается", count)
+		}
+		p.setRepeat('y', 0, nil)
+		return n + 1
+	}
+
+	// "каждое 1 число", "every 1st and 15th".
+	if days, m := p.monthDays(j); m > 0 {
+		p.setRepeat('m', 0, days)
+		return m
+	}
+	return 0
+}
+
+// matchMonthDays handles days put before the month: "on the 1st and 15th of
+// every month", "5 и 20 числа каждого месяца", "в последний день месяца".
+func (p *quickParser) matchMonthDays(i int) int {
+	j := i
+	for w := p.word(j); w == "on" || w == "the" || w == "в"; w = p.word(j) {
+		j++
+	}
+	days, n := p.dayList(j)
+	if n == 0 {
+		return 0
+	}
+	j += n
+
+	if w := p.word(j); w == "числа" || w == "число" {
+		j++
+	}
+	if p.word(j) == "of" {
+		j++
+	}
+	if w := p.word(j); everyWords[w] || w == "the" {
+		j++
+	}
+	if w := p.word(j); w != "month" && w != "месяца" {
+		return 0
+	}
+
+	p.setRepeat('m', 0, days)
+	return j + 1 - i
+}
+
+// monthDays reads an optional list of days after "every month", such as
+// "on the 1st and 15th" or "1 и 15 числа".
+func (p *quickParser) monthDays(j int) ([]int, int) {
+	start := j
+	for w := p.word(j); w == "on" || w == "the"; w = p.word(j) {
+		j++
+	}
+	days, n := p.dayList(j)
+	if n == 0 {
+		return nil, 0
+	}
+	j += n
+	if w := p.word(j); w == "числа" || w == "число" {
+		j++
+	}
+	return days, j - start
+}
+
+func (p *quickParser) dayList(j int) ([]int, int) {
+	var days []int
+	start := j
+	for {
+		day, n := p.monthDay(j)
+		if n == 0 {
+			break
+		}
+		days = append(days, day)
+		j += n
+		if listWords[p.word(j)] {
+			if _, m := p.monthDay(j + 1); m > 0 {
+				j++
+			}
+		}
+	}
+	return days, j - start
+}
+
+// monthDay reads a day of the month: "1st", "15", "1-го", "last day" or
+// "последнее число". Plain numbers must be followed by another day, "числа"
+// or "of" so that "every 3 days" is not read as a day of the month.
+func (p *quickParser) monthDay(j int) (int, int) {
+	w := p.word(j)
+	switch w {
+	case "last", "последний", "последнее", "последнего":
+		if quickUnits[p.word(j+1)] == "day" {
+			return -1, 2
+		}
+		return -1, 1
+	case "penultimate", "предпоследний", "предпоследнее", "предпоследнего":
+		if quickUnits[p.word(j+1)] == "day" {
+			return -2, 2
+		}
+		return -2, 1
+	}
+
+	if day, ok := ordinalDay(w); ok {
+		return day, 1
+	}
+	day, err := strconv.Atoi(w)
+	if err != nil || day < 1 || day > 31 {
+		return 0, 0
+	}
+	switch next := p.word(j + 1); {
+	case next == "числа" || next == "число" || next == "of" || listWords[next]:
+		return day, 1
+	}
+	return 0, 0
+}
+
+func (p *quickParser) weekdays(j int, names map[string]int) ([]int, int) {
+	var days []int
+	start := j
+	for names[p.word(j)] > 0 {
+		days = append(days, names[p.word(j)])
+		j++
+		if listWords[p.word(j)] && names[p.word(j+1)] > 0 {
+			j++
+		}
+	}
+	return days, j - start
+}
+
+// matchDate recognizes a date phrase at position i, optionally preceded by a
+// preposition, and returns the number of tokens it takes, or zero.
+func (p *quickParser) matchDate(i int) int {
+	if p.hasDate {
+		return 0
+	}
+	if datePrepositions[p.word(i)] {
+		if n := p.dateAt(i + 1); n > 0 {
+			return n + 1
+		}
+	}
+	return p.dateAt(i)
+}
+
+func (p *quickParser) setDate(t time.Time) {
+	p.date = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	p.hasDate = true
+}
+
+func (p *quickParser) dateAt(j int) int {
+	w := p.word(j)
+	switch w {
+	case "today", "сегодня":
+		p.setDate(p.today)
+		return 1
+	case "tomorrow", "завтра":
+		p.setDate(p.today.AddDate(0, 0, 1))
+		return 1
+	case "послезавтра":
+		p.setDate(p.today.AddDate(0, 0, 2))
+		return 1
+	case "day":
+		if p.word(j+1) == "after" && p.word(j+2) == "tomorrow" {
+			p.setDate(p.today.AddDate(0, 0, 2))
+			return 3
+		}
+	case "in", "через":
+		count, n := 1, 0
+		if num, ok := quickNumber(p.word(j + 1)); ok {
+			count, n = num, 1
+		}
+		switch quickUnits[p.word(j+1+n)] {
+		case "day":
+			p.setDate(p.today.AddDate(0, 0, count))
+		case "week":
+			p.setDate(p.today.AddDate(0, 0, 7*count))
+		case "month":
+			p.setDate(p.today.AddDate(0, count, 0))
+		case "year":
+			p.setDate(p.today.AddDate(count, 0, 0))
+		default:
+			return 0
+		}
+		return n + 2
+	case "next", "следующий", "следующую", "следующее":
+		if day := quickWeekdays[p.word(j+1)]; day > 0 {
+			p.setWeekday(day)
+			return 2
+		}
+	}
+
+	if day := quickWeekdays[w]; day > 0 {
+		p.setWeekday(day)
+		return 1
+	}
+
+	for _, layout := range []string{DateFormat, "2006-01-02", "2.1.2006"} {
+		if t, err := time.Parse(layout, w); err == nil {
+			p.setDate(t)
+			return 1
+		}
+	}
+	// A short "5.3" may as well be a number, as in "2.5 kg" or "version 1.2",
+	// so it is only a date after a preposition; "05.03" always is.
+	if day, month, ok := dotDayMonth(w); ok && (len(w) == len("02.01") || datePrepositions[p.word(j-1)]) {
+		p.setDayMonth(day, month, 0)
+		return 1
+	}
+
+	// "5 марта", "5th of march", "march 5" with an optional year.
+	if day, ok := calendarDay(w); ok {
+		n := 1
+		if p.word(j+n) == "of" {
+			n++
+		}
+		if month := quickMonths[p.word(j+n)]; month > 0 {
+			year, m := p.year(j + n + 1)
+			p.setDayMonth(day, month, year)
+			return n + 1 + m
+		}
+	}
+	if month := quickMonths[w]; month > 0 {
+		if day, ok := calendarDay(p.word(j + 1)); ok {
+			year, m := p.year(j + 2)
+			p.setDayMonth(day, month, year)
+			return 2 + m
+		}
+	}
+
+	return 0
+}
+
+func (p *quickParser) year(j int) (int, int) {
+	w := p.word(j)
+	if len(w) != 4 {
+		return 0, 0
+	}
+	year, err := strconv.Atoi(w)
+	if err != nil {
+		return 0, 0
+	}
+	if u := p.word(j + 1); u == "года" || u == "г" {
+		return year, 2
+	}
+	return year, 1
+}
+
+// setWeekday picks the nearest given weekday after today.
+func (p *quickParser) setWeekday(day int) {
+	current := int(p.today.Weekday())
+	if current == 0 {
+		current = 7
+	}
+	delta := (day - current + 7) % 7
+	if delta == 0 {
+		delta = 7
+	}
+	p.setDate(p.today.AddDate(0, 0, delta))
+}
+
+// setDayMonth uses the given year or, without one, the nearest such date from
+// today on. A day the month does not have, like "31 апреля", is an error
+// rather than a date in the next month.
+func (p *quickParser) setDayMonth(day int, month time.Month, year int) {
+	valid := func(t time.Time) bool {
+		return t.Day() == day && t.Month() == month
+	}
+	if year != 0 {
+		if t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC); valid(t) {
+			p.setDate(t)
+			return
+		}
+	} else {
+		// 29 February may be up to 8 years away.
+		today := p.today.Format(DateFormat)
+		for year := p.today.Year(); year <= p.today.Year()+8; year++ {
+			t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+			if valid(t) && t.Format(DateFormat) >= today {
+				p.setDate(t)
+				return
+			}
+		}
+	}
+	p.err = fmt.Errorf("Несуществующая дата: %02d.%02d", day, month)
+}
+
+// dotDayMonth parses "5.3" or "05.03" as a day and a month.
+func dotDayMonth(w string) (int, time.Month, bool) {
+	d, m, ok := strings.Cut(w, ".")
+	if !ok || len(d) > 2 || len(m) > 2 {
+		return 0, 0, false
+	}
+	day, err := strconv.Atoi(d)
+	if err != nil || day < 1 || day > 31 {
+		return 0, 0, false
+	}
+	month, err := strconv.Atoi(m)
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0, false
+	}
+	return day, time.Month(month), true
+}
+
+func quickNumber(w string) (int, bool) {
+	if n, ok := quickNumbers[w]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(w)
+	return n, err == nil && n > 0
+}
+
+// ordinalDay parses "1st", "22nd", "15th", "1-го" or "1-е".
+func ordinalDay(w string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th", "-го", "-е", "-ое"} {
+		if s, ok := strings.CutSuffix(w, suffix); ok {
+			day, err := strconv.Atoi(s)
+			return day, err == nil && day >= 1 && day <= 31
+		}
+	}
+	return 0, false
+}
+
+func calendarDay(w string) (int, bool) {
+	if day, ok := ordinalDay(w); ok {
+		return day, true
+	}
+	day, err := strconv.Atoi(w)
+	return day, err == nil && day >= 1 && day <= 31
+}
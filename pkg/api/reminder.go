@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+const maxReminderOffset = 365
+
+type APIReminder struct {
+	ID         string `json:"id"`
+	TaskID     string `json:"task_id"`
+	OffsetDays int    `json:"offset_days"`
+	Time       string `json:"time"`
+	SentFor    string `json:"sent_for,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+type RemindersResp struct {
+	Reminders []*APIReminder `json:"reminders"`
+}
+
+func (a *API) ReminderHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.addReminderHandler(w, r)
+	case http.MethodGet:
+		getRemindersHandler(w, r)
+	case http.MethodDelete:
+		deleteReminderHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) addReminderHandler(w http.ResponseWriter, r *http.Request) {
+	var apiReminder APIReminder
+
+	if err := json.NewDecoder(r.Body).Decode(&apiReminder); err != nil {
+		writeError(w, "Некорректный формат JSON", http.StatusBadRequest)
+		return
+	}
+
+	taskID, err := strconv.ParseInt(apiReminder.TaskID, 10, 64)
+	if err != nil {
+		writeError(w, "Некорректный идентификатор задачи", http.StatusBadRequest)
+		return
+	}
+
+	if apiReminder.OffsetDays < 0 || apiReminder.OffsetDays > maxReminderOffset {
+		writeError(w, fmt.Sprintf("Смещение напоминания должно быть от 0 до %d дней", maxReminderOffset), http.StatusBadRequest)
+		return
+	}
+
+	if apiReminder.Time == "" {
+		apiReminder.Time = "09:00"
+	}
+	if _, err := time.Parse("15:04", apiReminder.Time); err != nil || len(apiReminder.Time) != 5 {
+		writeError(w, "Время напоминания должно быть в формате ЧЧ:ММ", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := a.store.GetTask(r.Context(), apiReminder.TaskID); err != nil {
+		slog.ErrorContext(r.Context(), "error on getting task from database", "error", err)
+		writeDBError(w, err, "Задача не найдена", http.StatusNotFound)
+		return
+	}
+
+	id, err := db.AddReminder(r.Context(), &db.Reminder{
+		TaskID:     taskID,
+		OffsetDays: apiReminder.OffsetDays,
+		Time:       apiReminder.Time,
+		CreatedAt:  a.now().Format("2006-01-02 15:04"),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on adding reminder to database", "error", err)
+		writeDBError(w, err, "Ошибка добавления напоминания в базу данных", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, Response{ID: strconv.FormatInt(id, 10)})
+}
+
+func getRemindersHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := r.URL.Query().Get("task_id")
+	if taskID == "" {
+		writeError(w, "Не указан идентификатор задачи", http.StatusBadRequest)
+		return
+	}
+
+	reminders, err := db.GetReminders(r.Context(), taskID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on getting reminders from database", "error", err)
+		writeDBError(w, err, "Ошибка получения напоминаний из базы данных", http.StatusInternalServerError)
+		return
+	}
+
+	apiReminders := make([]*APIReminder, len(reminders))
+	for i, rem := range reminders {
+		apiReminders[i] = &APIReminder{
+			ID:         strconv.FormatInt(rem.ID, 10),
+			TaskID:     strconv.FormatInt(rem.TaskID, 10),
+			OffsetDays: rem.OffsetDays,
+			Time:       rem.Time,
+			SentFor:    rem.SentFor,
+			LastError:  rem.LastError,
+		}
+	}
+
+	writeJSON(w, RemindersResp{Reminders: apiReminders})
+}
+
+func deleteReminderHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, "Не указан идентификатор напоминания", http.StatusBadRequest)
+		return
+	}
+
+	err := db.DeleteReminder(r.Context(), id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on deleting reminder from database", "error", err)
+		writeDBError(w, err, "Напоминание не найдено", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, Response{})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/snapshot"
+)
+
+// SnapshotsHandler lists the database snapshots on GET and takes a new one on
+// POST. Old snapshots beyond TODO_BACKUP_KEEP are removed.
+func (a *API) SnapshotsHandler(w http.ResponseWriter, r *http.Request) {
+	manager := &snapshot.Manager{
+		Dir:  a.cfg.BackupDir,
+		Keep: a.cfg.BackupKeep,
+		Now:  a.now,
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		snapshots, err := manager.List()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error when list snapshots", "error", err)
+			writeError(w, "Ошибка при получении списка снимков", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string][]snapshot.Snapshot{"snapshots": snapshots})
+	case http.MethodPost:
+		// A large database may take longer than the server write timeout.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		s, err := manager.Create(r.Context())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error when take snapshot", "error", err)
+			writeDBError(w, err, "Ошибка при создании снимка базы данных", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, s)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -2,8 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
@@ -12,67 +13,80 @@ import (
 )
 
 type APITask struct {
-	ID      string `json:"id"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment"`
-	Repeat  string `json:"repeat"`
+	ID        string `json:"id"`
+	Date      string `json:"date"`
+	Title     string `json:"title"`
+	Comment   string `json:"comment"`
+	Repeat    string `json:"repeat"`
+	Highlight string `json:"highlight,omitempty"`
+	Snippet   string `json:"snippet,omitempty"`
 }
 
-func TaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) TaskHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
-		addTaskHandler(w, r)
+		a.addTaskHandler(w, r)
 	case http.MethodGet:
-		getTaskHandler(w, r)
+		a.getTaskHandler(w, r)
 	case http.MethodPut:
-		updateTaskHandler(w, r)
+		a.updateTaskHandler(w, r)
 	case http.MethodDelete:
-		deleteTaskHandler(w, r)
+		a.deleteTaskHandler(w, r)
 	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func addTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) addTaskHandler(w http.ResponseWriter, r *http.Request) {
+	if id, ok := a.addTask(w, r); ok {
+		writeJSON(w, Response{ID: fmt.Sprintf("%d", id)})
+	}
+}
+
+// addTask stores the task from the request body. On failure it writes the
+// error response and returns false.
+func (a *API) addTask(w http.ResponseWriter, r *http.Request) (int64, bool) {
 	var task db.Task
 
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		writeError(w, "Некорректный формат JSON", http.StatusBadRequest)
-		return
+		return 0, false
 	}
 
 	if task.Title == "" {
 		writeError(w, "Не указан заголовок задачи", http.StatusBadRequest)
-		return
+		return 0, false
 	}
 
-	if err := checkDate(&task); err != nil {
+	if err := checkDate(&task, a.now()); err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
-		return
+		return 0, false
 	}
 
-	id, err := db.AddTask(&task)
+	id, err := a.store.AddTask(r.Context(), &task)
 	if err != nil {
-		log.Println("error on adding task to database:", err)
-		writeError(w, "Ошибка добавления задачи в базу данных", http.StatusInternalServerError)
-		return
+		slog.ErrorContext(r.Context(), "error on adding task to database", "error", err)
+		writeDBError(w, err, "Ошибка добавления задачи в базу данных", http.StatusInternalServerError)
+		return 0, false
 	}
-
-	writeJSON(w, Response{ID: fmt.Sprintf("%d", id)})
+	return id, true
 }
 
-func getTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		writeError(w, "Не указан идентификатор", http.StatusBadRequest)
 		return
 	}
+	a.getTask(w, r, id)
+}
 
-	t, err := db.GetTask(id)
+func (a *API) getTask(w http.ResponseWriter, r *http.Request, id string) {
+	t, err := a.store.GetTask(r.Context(), id)
 	if err != nil {
-		log.Println("error on getting task from database:", err)
-		writeError(w, "Задача не найдена", http.StatusNotFound)
+		slog.ErrorContext(r.Context(), "error on getting task from database", "error", err)
+		writeDBError(w, err, "Задача не найдена", http.StatusNotFound)
 		return
 	}
 	apiTask := &APITask{
@@ -86,7 +100,7 @@ func getTaskHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, apiTask)
 }
 
-func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	var apiTask APITask
 
 	if err := json.NewDecoder(r.Body).Decode(&apiTask); err != nil {
@@ -99,6 +113,14 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if id == 0 {
+		writeError(w, "Не указан идентификатор задачи", http.StatusBadRequest)
+		return
+	}
+	a.updateTask(w, r, id, &apiTask)
+}
+
+func (a *API) updateTask(w http.ResponseWriter, r *http.Request, id int64, apiTask *APITask) {
 	task := db.Task{
 		ID:      id,
 		Date:    apiTask.Date,
@@ -107,33 +129,26 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		Repeat:  apiTask.Repeat,
 	}
 
-	if task.ID == 0 {
-		writeError(w, "Не указан идентификатор задачи", http.StatusBadRequest)
-		return
-	}
-
 	if task.Title == "" {
 		writeError(w, "Не указан заголовок задачи", http.StatusBadRequest)
 		return
 	}
 
-	if err := checkDate(&task); err != nil {
+	if err := checkDate(&task, a.now()); err != nil {
 		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = db.UpdateTask(&task)
-	if err != nil {
-		writeError(w, "Задача не найдена", http.StatusNotFound)
-		log.Println("error on task update in database:", err)
+	if err := a.store.UpdateTask(r.Context(), &task); err != nil {
+		writeDBError(w, err, "Задача не найдена", http.StatusNotFound)
+		slog.ErrorContext(r.Context(), "error on task update in database", "error", err)
 		return
 	}
 
 	writeJSON(w, Response{})
 }
 
-func checkDate(task *db.Task) error {
-	now := time.Now()
+func checkDate(task *db.Task, now time.Time) error {
 
 	if task.Date == "" {
 		task.Date = now.Format(DateFormat)
@@ -165,39 +180,43 @@ func checkDate(task *db.Task) error {
 	return nil
 }
 
-func DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		writeError(w, "Не указан идентификатор задачи", http.StatusBadRequest)
 		return
 	}
+	a.doneTask(w, r, id)
+}
 
-	task, err := db.GetTask(id)
+// doneTask deletes a one-off task and moves a repeating one to its next date.
+func (a *API) doneTask(w http.ResponseWriter, r *http.Request, id string) {
+	task, err := a.store.GetTask(r.Context(), id)
 	if err != nil {
-		log.Println("error on getting task from database:", err)
-		writeError(w, "Задача не найдена", http.StatusNotFound)
+		slog.ErrorContext(r.Context(), "error on getting task from database", "error", err)
+		writeDBError(w, err, "Задача не найдена", http.StatusNotFound)
 		return
 	}
 
 	if task.Repeat == "" {
-		err = db.DeleteTask(id)
+		err = a.store.DeleteTask(r.Context(), id)
 		if err != nil {
-			log.Println("error on deleting task from database:", err)
-			writeError(w, fmt.Sprintf("Ошибка удаления задачи: %v", err), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "error on deleting task from database", "error", err)
+			writeDBError(w, err, fmt.Sprintf("Ошибка удаления задачи: %v", err), http.StatusInternalServerError)
 			return
 		}
 	} else {
-		now := time.Now()
+		now := a.now()
 		nextDate, err := NextDate(now, task.Date, task.Repeat)
 		if err != nil {
-			log.Println("error on calculating next date:", err)
+			slog.ErrorContext(r.Context(), "error on calculating next date", "error", err)
 			writeError(w, fmt.Sprintf("Ошибка расчета следующей даты: %v", err), http.StatusInternalServerError)
 			return
 		}
-		err = db.UpdateDate(nextDate, id)
+		err = a.store.UpdateDate(r.Context(), nextDate, id)
 		if err != nil {
-			log.Println("error on updating task date in database:", err)
-			writeError(w, fmt.Sprintf("Ошибка обновления даты задачи: %v", err), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "error on updating task date in database", "error", err)
+			writeDBError(w, err, fmt.Sprintf("Ошибка обновления даты задачи: %v", err), http.StatusInternalServerError)
 			return
 		}
 	}
@@ -205,17 +224,24 @@ func DoneTaskHandler(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, Response{})
 }
 
-func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (a *API) deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		writeError(w, "Не указан идентификатор задачи", http.StatusBadRequest)
 		return
 	}
+	a.deleteTask(w, r, id)
+}
 
-	err := db.DeleteTask(id)
+func (a *API) deleteTask(w http.ResponseWriter, r *http.Request, id string) {
+	err := a.store.DeleteTask(r.Context(), id)
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, "Задача не найдена", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Println("error on deleting task from database:", err)
-		writeError(w, fmt.Sprintf("Ошибка удаления задачи: %v", err), http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error on deleting task from database", "error", err)
+		writeDBError(w, err, fmt.Sprintf("Ошибка удаления задачи: %v", err), http.StatusInternalServerError)
 		return
 	}
 
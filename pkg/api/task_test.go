@@ -0,0 +1,332 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ElenaMask/go_final_project/pkg/config"
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+func TestTaskHandlersWithMemoryStore(t *testing.T) {
+	h := New(db.NewMemoryStore(), nil, config.Default())
+	date := time.Now().AddDate(0, 0, 3).Format(DateFormat)
+
+	rec := httptest.NewRecorder()
+	h.TaskHandler(rec, httptest.NewRequest(http.MethodPost, "/api/task",
+		strings.NewReader(`{"date":"`+date+`","title":"Отчёт","comment":"квартальный","repeat":"d 7"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.NotEmpty(t, resp.ID)
+
+	rec = httptest.NewRecorder()
+	h.TasksHandler(rec, httptest.NewRequest(http.MethodGet, "/api/tasks?search=отчет", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var tasks TasksResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &tasks))
+	if assert.Len(t, tasks.Tasks, 1) {
+		assert.Equal(t, resp.ID, tasks.Tasks[0].ID)
+		assert.Equal(t, "<mark>Отчёт</mark>", tasks.Tasks[0].Highlight)
+	}
+
+	rec = httptest.NewRecorder()
+	h.DoneTaskHandler(rec, httptest.NewRequest(http.MethodPost, "/api/task/done?id="+resp.ID, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	h.TaskHandler(rec, httptest.NewRequest(http.MethodGet, "/api/task?id="+resp.ID, nil))
+	var task APITask
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &task))
+	next, err := time.Parse(DateFormat, date)
+	require.NoError(t, err)
+	assert.Equal(t, next.AddDate(0, 0, 7).Format(DateFormat), task.Date)
+
+	rec = httptest.NewRecorder()
+	h.TaskHandler(rec, httptest.NewRequest(http.MethodGet, "/api/task?id=999", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// slowStore never answers a listing before the deadline of the query.
+type slowStore struct {
+	*db.MemoryStore
+}
+
+func (slowStore) Tasks(ctx context.Context, after db.Cursor, limit int) ([]*db.Task, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTasksHandlerTimeout(t *testing.T) {
+	h := New(slowStore{db.NewMemoryStore()}, nil, config.Default())
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	h.TasksHandler(rec, httptest.NewRequest(http.MethodGet, "/api/tasks", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	var resp Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Error)
+}
+
+// brokenStore fails every lookup of a single task.
+type brokenStore struct {
+	*db.MemoryStore
+}
+
+func (brokenStore) GetTask(ctx context.Context, id string) (*db.Task, error) {
+	return nil, errors.New("disk I/O error")
+}
+
+func TestImportCSVStoreError(t *testing.T) {
+	h := New(brokenStore{db.NewMemoryStore()}, nil, config.Default())
+
+	rec := httptest.NewRecorder()
+	h.ImportCSVHandler(rec, httptest.NewRequest(http.MethodPost, "/api/import.csv?mode=upsert",
+		strings.NewReader("id,title\n1,Отчёт\n")))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var resp CSVImportResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotContains(t, resp.Error, "не найдена")
+	assert.Empty(t, resp.Rows)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := New(store, nil, config.Default())
+	today := time.Now().Format(DateFormat)
+	for _, task := range []db.Task{
+		{Date: "20200101", Title: "Просрочено"},
+		{Date: today, Title: "Сегодня"},
+		{Date: today, Title: "Каждый день", Repeat: "d 1"},
+	} {
+		_, err := store.AddTask(t.Context(), &task)
+		require.NoError(t, err)
+	}
+	_, err := NextDate(time.Now(), "20240101", "w 8")
+	require.Error(t, err)
+
+	rec := httptest.NewRecorder()
+	h.MetricsHandler(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	body := rec.Body.String()
+	assert.Contains(t, body, `todo_tasks{state="overdue"} 1`)
+	assert.Contains(t, body, `todo_tasks{state="today"} 1`)
+	assert.Contains(t, body, `todo_tasks{state="future"} 0`)
+	assert.Contains(t, body, `todo_tasks{state="repeating"} 1`)
+	assert.Contains(t, body, `todo_nextdate_errors_total{rule="w"}`)
+}
+
+func TestReadyzHandler(t *testing.T) {
+	ready := func(h *API) (int, ReadyResp) {
+		rec := httptest.NewRecorder()
+		h.ReadyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var resp ReadyResp
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+	web := fstest.MapFS{"index.html": {Data: []byte("<html></html>")}}
+
+	cfg := config.Default()
+	code, resp := ready(New(db.NewMemoryStore(), web, cfg))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp.Status)
+	assert.Equal(t, map[string]*ReadyCheck{"web": {Status: "ok"}}, resp.Checks)
+
+	code, resp = ready(New(db.NewMemoryStore(), fstest.MapFS{}, cfg))
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "degraded", resp.Status)
+	assert.Equal(t, "failed", resp.Checks["web"].Status)
+
+	cfg.DBFile = filepath.Join(t.TempDir(), "scheduler.db")
+	require.NoError(t, db.Init(cfg.DBFile))
+	cfg.ReadyMinFreeMB = 0
+	code, resp = ready(New(db.SQLiteStore{}, web, cfg))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", resp.Checks["database"].Status)
+	assert.Equal(t, "ok", resp.Checks["schema"].Status)
+	assert.Positive(t, resp.Checks["schema"].Version)
+	if resp.Checks["disk"].Status != "unknown" {
+		assert.Equal(t, "ok", resp.Checks["disk"].Status)
+		assert.NotNil(t, resp.Checks["disk"].FreeBytes)
+
+		cfg.ReadyMinFreeMB = 1 << 40
+		code, resp = ready(New(db.SQLiteStore{}, web, cfg))
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "failed", resp.Checks["disk"].Status)
+	}
+}
+
+func TestTimerHandlers(t *testing.T) {
+	require.NoError(t, db.Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	taskID, err := db.AddTask(t.Context(), &db.Task{Date: "20261019", Title: "Таймер"})
+	require.NoError(t, err)
+
+	cfg := config.Default()
+	cfg.Location = time.UTC
+	h := New(db.SQLiteStore{}, fstest.MapFS{}, cfg)
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	h.clock = func() time.Time { return now }
+
+	timer := func(handler http.HandlerFunc, method, target string) (int, TimerResp) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, target, nil))
+		var resp TimerResp
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	rec := httptest.NewRecorder()
+	h.StartTimerHandler(rec, httptest.NewRequest(http.MethodPost, "/api/timer/start?id="+strconv.FormatInt(taskID, 10), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	now = now.Add(90 * time.Second)
+	_, resp := timer(h.TimerHandler, http.MethodGet, "/api/timer")
+	require.NotNil(t, resp.Timer)
+	assert.Equal(t, int64(90), resp.Timer.Duration)
+	assert.Equal(t, "2026-10-19T09:00:00Z", resp.Timer.StartedAt)
+
+	now = now.Add(210 * time.Second)
+	code, resp := timer(h.StopTimerHandler, http.MethodPost, "/api/timer/stop")
+	assert.Equal(t, http.StatusOK, code)
+	require.NotNil(t, resp.Timer)
+	assert.Equal(t, int64(300), resp.Timer.Duration)
+	assert.Equal(t, "2026-10-19T09:05:00Z", resp.Timer.StoppedAt)
+
+	code, _ = timer(h.StopTimerHandler, http.MethodPost, "/api/timer/stop")
+	assert.Equal(t, http.StatusConflict, code)
+
+	// Only a missing task or entry is a 404; a failing database is a 500.
+	start := func() int {
+		rec := httptest.NewRecorder()
+		h.StartTimerHandler(rec, httptest.NewRequest(http.MethodPost, "/api/timer/start?id=42", nil))
+		return rec.Code
+	}
+	deleteEntry := func() int {
+		rec := httptest.NewRecorder()
+		h.TimeHandler(rec, httptest.NewRequest(http.MethodDelete, "/api/time?id=42", nil))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusNotFound, start())
+	assert.Equal(t, http.StatusNotFound, deleteEntry())
+	require.NoError(t, db.Close())
+	assert.Equal(t, http.StatusInternalServerError, start())
+	assert.Equal(t, http.StatusInternalServerError, deleteEntry())
+}
+
+func TestAgendaKeepsEarliestOccurrences(t *testing.T) {
+	limit := maxAgendaOccurrences
+	maxAgendaOccurrences = 5
+	t.Cleanup(func() { maxAgendaOccurrences = limit })
+
+	store := db.NewMemoryStore()
+	_, err := store.AddTask(t.Context(), &db.Task{Date: "20261019", Title: "Каждый день", Repeat: "d 1"})
+	require.NoError(t, err)
+	_, err = store.AddTask(t.Context(), &db.Task{Date: "20261020", Title: "Завтра"})
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	New(store, fstest.MapFS{}, config.Default()).AgendaHandler(rec,
+		httptest.NewRequest(http.MethodGet, "/api/agenda?now=20261019&from=20261019&to=20261030", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp AgendaResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.True(t, resp.Truncated)
+	var got []string
+	for _, o := range resp.Occurrences {
+		got = append(got, o.Date+" "+o.Title)
+	}
+	assert.Equal(t, []string{
+		"20261019 Каждый день",
+		"20261020 Каждый день",
+		"20261020 Завтра",
+		"20261021 Каждый день",
+		"20261022 Каждый день",
+	}, got)
+}
+
+func TestQuickTaskUsesNowParam(t *testing.T) {
+	h := New(db.NewMemoryStore(), nil, config.Default())
+	h.clock = func() time.Time { return time.Date(2026, 12, 1, 10, 0, 0, 0, time.UTC) }
+
+	rec := httptest.NewRecorder()
+	h.QuickTaskHandler(rec, httptest.NewRequest(http.MethodPost, "/api/task/quick?now=20261019",
+		strings.NewReader(`{"text":"Отчёт 25.10.2026"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp QuickTaskResp
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.ID)
+	assert.Equal(t, "20261025", resp.Date)
+}
+
+func TestRRuleRepeatDailyByDay(t *testing.T) {
+	repeat, issues := rruleRepeat("FREQ=DAILY;BYDAY=MO,FR", "20261019")
+	assert.Equal(t, "w 1,5", repeat)
+	assert.Empty(t, issues)
+
+	repeat, issues = rruleRepeat("FREQ=DAILY;INTERVAL=2;BYDAY=MO,FR", "20261019")
+	assert.Equal(t, "d 2", repeat)
+	if assert.Len(t, issues, 1) {
+		assert.Contains(t, issues[0], "BYDAY=MO,FR")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	cfg := config.Default()
+	h := New(db.NewMemoryStore(), nil, cfg)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	h.clock = func() time.Time { return now }
+	tasks := h.Auth(h.TasksHandler)
+
+	list := func(header ...string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		tasks(rec, req)
+		return rec.Code
+	}
+	signin := func(password string) (int, string) {
+		rec := httptest.NewRecorder()
+		h.SigninHandler(rec, httptest.NewRequest(http.MethodPost, "/api/signin",
+			strings.NewReader(`{"password":"`+password+`"}`)))
+		var resp SigninResp
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec.Code, resp.Token
+	}
+
+	assert.Equal(t, http.StatusOK, list(), "без пароля API открыт")
+
+	cfg.Password = "secret"
+	assert.Equal(t, http.StatusUnauthorized, list())
+	code, _ := signin("wrong")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, token := signin("secret")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, http.StatusOK, list("Cookie", "token="+token))
+	assert.Equal(t, http.StatusOK, list("Authorization", "Bearer "+token))
+	assert.Equal(t, http.StatusUnauthorized, list("Cookie", "token="+token+"0"))
+
+	now = now.Add(tokenTTL)
+	assert.Equal(t, http.StatusUnauthorized, list("Cookie", "token="+token), "токен истёк")
+
+	_, token = signin("secret")
+	cfg.Password = "changed"
+	assert.Equal(t, http.StatusUnauthorized, list("Cookie", "token="+token), "смена пароля завершает сессии")
+}
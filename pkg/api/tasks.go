@@ -1,51 +1,298 @@
 package api
 
 import (
+	"encoding/base64"
+	"errors"
+	"html"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ElenaMask/go_final_project/pkg/db"
 )
 
+// TasksResp carries pagination fields only when the client asked for a page
+// with limit or cursor, so the plain listing keeps its original shape.
 type TasksResp struct {
-	Tasks []*APITask `json:"tasks"`
+	Tasks      []*APITask `json:"tasks"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Total      *int       `json:"total,omitempty"`
 }
 
-func TasksHandler(w http.ResponseWriter, r *http.Request) {
-	searchQuery := r.URL.Query().Get("search")
+// QueryErrorResp points at the offending token of an invalid search query.
+type QueryErrorResp struct {
+	Error    string `json:"error"`
+	Position int    `json:"position"`
+	Token    string `json:"token"`
+}
+
+func (a *API) TasksHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	searchQuery := query.Get("search")
+
+	limit, err := a.tasksLimit(query.Get("limit"))
+	if err != nil {
+		writeError(w, "Некорректный параметр limit", http.StatusBadRequest)
+		return
+	}
+
+	var after db.Cursor
+	if c := query.Get("cursor"); c != "" {
+		after, err = decodeCursor(c)
+		if err != nil {
+			writeError(w, "Некорректный параметр cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	from, to, err := a.tasksRange(query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hasRange := from != "" || to != ""
+	if hasRange && searchQuery != "" {
+		writeError(w, "Параметр search нельзя совмещать с from, to и view", http.StatusBadRequest)
+		return
+	}
+
+	// A bare 02.01.2006 date keeps listing the tasks of that day, anything
+	// else is parsed with the search query grammar.
+	dateSearch := ""
+	var searchQ *db.Query
+	if parsedTime, dateErr := time.Parse("02.01.2006", searchQuery); dateErr == nil {
+		dateSearch = parsedTime.Format(DateFormat)
+	} else if searchQuery != "" {
+		searchQ, err = db.ParseQuery(searchQuery, a.now())
+		if err != nil {
+			syntaxErr := &db.SyntaxError{Pos: 1, Token: searchQuery, Msg: err.Error()}
+			errors.As(err, &syntaxErr)
+			writeJSONStatus(w, http.StatusBadRequest, QueryErrorResp{
+				Error:    "Ошибка в поисковом запросе: " + syntaxErr.Error(),
+				Position: syntaxErr.Pos,
+				Token:    syntaxErr.Token,
+			})
+			return
+		}
+	}
+	// Full-text results are ordered by relevance, everything else by date.
+	ranked := searchQ != nil && searchQ.Ranked()
+	if !after.IsZero() && ranked != (after.Date == "") {
+		writeError(w, "Некорректный параметр cursor", http.StatusBadRequest)
+		return
+	}
+
+	var results []*db.SearchResult
 	var tasks []*db.Task
-	var err error
+	var total int
 
-	if searchQuery != "" {
-		parsedTime, dateErr := time.Parse("02.01.2006", searchQuery)
-		if dateErr == nil {
-			dateFormatted := parsedTime.Format("20060102")
-			tasks, err = db.GetTasksByDate(dateFormatted, 50)
-		} else {
-			tasks, err = db.SearchTasks(searchQuery, 50)
+	// One extra row tells whether there is a next page.
+	switch {
+	case hasRange:
+		tasks, err = a.store.TasksInRange(r.Context(), from, to, after, limit+1)
+		if err == nil {
+			total, err = a.store.CountTasksInRange(r.Context(), from, to)
+		}
+	case dateSearch != "":
+		tasks, err = a.store.GetTasksByDate(r.Context(), dateSearch, after, limit+1)
+		if err == nil {
+			total, err = a.store.CountTasksByDate(r.Context(), dateSearch)
+		}
+	case searchQ != nil:
+		results, err = a.store.SearchTasks(r.Context(), searchQ, after, limit+1)
+		if err == nil {
+			total, err = a.store.CountSearchTasks(r.Context(), searchQ)
+		}
+	default:
+		tasks, err = a.store.Tasks(r.Context(), after, limit+1)
+		if err == nil {
+			total, err = a.store.CountTasks(r.Context())
 		}
-	} else {
-		tasks, err = db.Tasks(50)
 	}
 
 	if err != nil {
-		writeError(w, "Ошибка получения задач из базы данных", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "error on getting tasks from database", "error", err)
+		writeDBError(w, err, "Ошибка получения задач из базы данных", http.StatusInternalServerError)
 		return
 	}
 
-	apiTasks := make([]*APITask, len(tasks))
-	for i, t := range tasks {
+	if results == nil {
+		results = make([]*db.SearchResult, len(tasks))
+		for i, t := range tasks {
+			results[i] = &db.SearchResult{Task: *t}
+		}
+	}
+
+	var nextCursor string
+	if len(results) > limit {
+		results = results[:limit]
+		last := results[len(results)-1]
+		if ranked {
+			nextCursor = encodeCursor(db.Cursor{Rank: last.Rank, ID: last.ID})
+		} else {
+			nextCursor = encodeCursor(db.Cursor{Date: last.Date, ID: last.ID})
+		}
+	}
+
+	apiTasks := make([]*APITask, len(results))
+	for i, t := range results {
 		apiTasks[i] = &APITask{
-			ID:      strconv.FormatInt(t.ID, 10),
-			Date:    t.Date,
-			Title:   t.Title,
-			Comment: t.Comment,
-			Repeat:  t.Repeat,
+			ID:        strconv.FormatInt(t.ID, 10),
+			Date:      t.Date,
+			Title:     t.Title,
+			Comment:   t.Comment,
+			Repeat:    t.Repeat,
+			Highlight: highlightHTML(t.Highlight),
+			Snippet:   highlightHTML(t.Snippet),
 		}
 	}
 
-	writeJSON(w, TasksResp{
+	resp := TasksResp{
 		Tasks: apiTasks,
-	})
+	}
+	if query.Has("limit") || query.Has("cursor") {
+		resp.NextCursor = nextCursor
+		resp.Total = &total
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if nextCursor != "" {
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
+	writeJSON(w, resp)
+}
+
+// tasksRange turns the view or from/to parameters into an inclusive date
+// range. Views are computed relative to today in the configured time zone, or
+// to the now parameter if it is given.
+func (a *API) tasksRange(query url.Values) (from, to string, err error) {
+	view := query.Get("view")
+	fromParam, toParam := query.Get("from"), query.Get("to")
+
+	if view == "" {
+		if fromParam != "" {
+			if from, err = parseDateParam(fromParam); err != nil {
+				return "", "", errors.New("Некорректный параметр from")
+			}
+		}
+		if toParam != "" {
+			if to, err = parseDateParam(toParam); err != nil {
+				return "", "", errors.New("Некорректный параметр to")
+			}
+		}
+		if from != "" && to != "" && from > to {
+			return "", "", errors.New("Параметр from не может быть позже to")
+		}
+		return from, to, nil
+	}
+
+	if fromParam != "" || toParam != "" {
+		return "", "", errors.New("Параметр view нельзя совмещать с from и to")
+	}
+
+	today := a.now()
+	if nowParam := query.Get("now"); nowParam != "" {
+		if today, err = time.Parse(DateFormat, nowParam); err != nil {
+			return "", "", errors.New("Некорректный параметр now")
+		}
+	}
+
+	switch view {
+	case "overdue":
+		return "", today.AddDate(0, 0, -1).Format(DateFormat), nil
+	case "today":
+		return today.Format(DateFormat), today.Format(DateFormat), nil
+	case "week":
+		return today.Format(DateFormat), today.AddDate(0, 0, 6).Format(DateFormat), nil
+	case "upcoming":
+		return today.AddDate(0, 0, 1).Format(DateFormat), "", nil
+	default:
+		return "", "", errors.New("Параметр view должен быть одним из: overdue, today, week, upcoming")
+	}
+}
+
+// parseDateParam accepts both the API format 20060102 and the 02.01.2006
+// format used by the search box.
+func parseDateParam(s string) (string, error) {
+	t, err := time.Parse(DateFormat, s)
+	if err != nil {
+		t, err = time.Parse("02.01.2006", s)
+		if err != nil {
+			return "", err
+		}
+	}
+	return t.Format(DateFormat), nil
+}
+
+func (a *API) tasksLimit(param string) (int, error) {
+	if param == "" {
+		return min(a.cfg.TasksLimit, a.cfg.MaxTasksLimit), nil
+	}
+
+	limit, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		return 0, errors.New("limit must be positive")
+	}
+	return min(limit, a.cfg.MaxTasksLimit), nil
+}
+
+// Cursors are "d:<date>:<id>" for date order and "r:<rank>:<id>" for search
+// results, base64 encoded so that clients treat them as opaque.
+func encodeCursor(c db.Cursor) string {
+	var raw string
+	if c.Date != "" {
+		raw = "d:" + c.Date + ":" + strconv.FormatInt(c.ID, 10)
+	} else {
+		raw = "r:" + strconv.FormatFloat(c.Rank, 'g', -1, 64) + ":" + strconv.FormatInt(c.ID, 10)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (db.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return db.Cursor{}, err
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return db.Cursor{}, errors.New("malformed cursor")
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return db.Cursor{}, err
+	}
+
+	switch parts[0] {
+	case "d":
+		if _, err := time.Parse(DateFormat, parts[1]); err != nil {
+			return db.Cursor{}, err
+		}
+		return db.Cursor{Date: parts[1], ID: id}, nil
+	case "r":
+		rank, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return db.Cursor{}, err
+		}
+		return db.Cursor{Rank: rank, ID: id}, nil
+	default:
+		return db.Cursor{}, errors.New("unknown cursor kind")
+	}
+}
+
+// highlightHTML escapes the text and turns the highlight markers set by the
+// search into <mark> tags.
+func highlightHTML(s string) string {
+	if s == "" {
+		return ""
+	}
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, db.HighlightStart, "<mark>")
+	return strings.ReplaceAll(s, db.HighlightEnd, "</mark>")
 }
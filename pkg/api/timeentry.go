@@ -0,0 +1,309 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+type APITimeEntry struct {
+	ID         string `json:"id"`
+	TaskID     string `json:"task_id"`
+	Occurrence string `json:"occurrence"`
+	Day        string `json:"day"`
+	StartedAt  string `json:"started_at"`
+	StoppedAt  string `json:"stopped_at,omitempty"`
+	Duration   int64  `json:"duration"`
+	Comment    string `json:"comment"`
+}
+
+type TimeEntriesResp struct {
+	Entries []*APITimeEntry `json:"entries"`
+	Total   int64           `json:"total"`
+}
+
+type TimerResp struct {
+	Timer *APITimeEntry `json:"timer,omitempty"`
+}
+
+type APITimeTotal struct {
+	TaskID      string               `json:"task_id"`
+	Title       string               `json:"title"`
+	Duration    int64                `json:"duration"`
+	Occurrences []*APIOccurrenceTime `json:"occurrences"`
+}
+
+type APIOccurrenceTime struct {
+	Date     string `json:"date"`
+	Duration int64  `json:"duration"`
+}
+
+type TimeTotalsResp struct {
+	Tasks []*APITimeTotal `json:"tasks"`
+	Total int64           `json:"total"`
+}
+
+type addTimeEntryRequest struct {
+	TaskID  string `json:"task_id"`
+	Day     string `json:"day"`
+	Minutes int64  `json:"minutes"`
+	Comment string `json:"comment"`
+}
+
+// newAPITimeEntry formats the entry in the zone of now; a running entry lasts
+// until now.
+func newAPITimeEntry(e *db.TimeEntry, now time.Time) *APITimeEntry {
+	apiEntry := &APITimeEntry{
+		ID:         strconv.FormatInt(e.ID, 10),
+		TaskID:     strconv.FormatInt(e.TaskID, 10),
+		Occurrence: e.Occurrence,
+		Day:        e.Day,
+		StartedAt:  time.Unix(e.StartedAt, 0).In(now.Location()).Format(time.RFC3339),
+		Duration:   e.Duration,
+		Comment:    e.Comment,
+	}
+	if e.StoppedAt != nil {
+		apiEntry.StoppedAt = time.Unix(*e.StoppedAt, 0).In(now.Location()).Format(time.RFC3339)
+	} else {
+		apiEntry.Duration = max(0, now.Unix()-e.StartedAt)
+	}
+	return apiEntry
+}
+
+func (a *API) TimerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	running, err := db.RunningTimer(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on getting running timer from database", "error", err)
+		writeDBError(w, err, "Ошибка получения таймера из базы данных", http.StatusInternalServerError)
+		return
+	}
+
+	var resp TimerResp
+	if running != nil {
+		resp.Timer = newAPITimeEntry(running, a.now())
+	}
+	writeJSON(w, resp)
+}
+
+func (a *API) StartTimerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, "Не указан идентификатор задачи", http.StatusBadRequest)
+		return
+	}
+
+	now := a.now()
+	entryID, err := db.StartTimer(r.Context(), id, now.Format(DateFormat), now.Unix())
+	if errors.Is(err, db.ErrTimerRunning) {
+		writeError(w, "Таймер уже запущен, сначала остановите его", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, "Задача не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on starting timer", "error", err)
+		writeDBError(w, err, "Ошибка запуска таймера", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, Response{ID: strconv.FormatInt(entryID, 10)})
+}
+
+func (a *API) StopTimerHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := a.now()
+	entry, err := db.StopTimer(r.Context(), now.Unix())
+	if errors.Is(err, db.ErrNoRunningTimer) {
+		writeError(w, "Нет запущенного таймера", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on stopping timer", "error", err)
+		writeDBError(w, err, "Ошибка остановки таймера", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, TimerResp{Timer: newAPITimeEntry(entry, now)})
+}
+
+func (a *API) TimeHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		a.addTimeEntryHandler(w, r)
+	case http.MethodGet:
+		a.getTimeEntriesHandler(w, r)
+	case http.MethodDelete:
+		a.deleteTimeEntryHandler(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) addTimeEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var req addTimeEntryRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Некорректный формат JSON", http.StatusBadRequest)
+		return
+	}
+
+	taskID, err := strconv.ParseInt(req.TaskID, 10, 64)
+	if err != nil {
+		writeError(w, "Некорректный идентификатор задачи", http.StatusBadRequest)
+		return
+	}
+
+	if req.Minutes <= 0 || req.Minutes > 24*60 {
+		writeError(w, "Длительность должна быть от 1 до 1440 минут", http.StatusBadRequest)
+		return
+	}
+
+	now := a.now()
+	if req.Day == "" {
+		req.Day = now.Format(DateFormat)
+	}
+	if _, err := time.Parse(DateFormat, req.Day); err != nil {
+		writeError(w, "Некорректная дата", http.StatusBadRequest)
+		return
+	}
+
+	stoppedAt := now.Unix()
+	id, err := db.AddTimeEntry(r.Context(), &db.TimeEntry{
+		TaskID:    taskID,
+		Day:       req.Day,
+		StartedAt: stoppedAt - req.Minutes*60,
+		StoppedAt: &stoppedAt,
+		Duration:  req.Minutes * 60,
+		Comment:   req.Comment,
+	})
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, "Задача не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on adding time entry to database", "error", err)
+		writeDBError(w, err, "Ошибка добавления записи времени в базу данных", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, Response{ID: strconv.FormatInt(id, 10)})
+}
+
+func (a *API) getTimeEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, from, to, ok := timeFilterParams(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := db.TimeEntries(r.Context(), taskID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on getting time entries from database", "error", err)
+		writeDBError(w, err, "Ошибка получения записей времени из базы данных", http.StatusInternalServerError)
+		return
+	}
+
+	now := a.now()
+	resp := TimeEntriesResp{Entries: make([]*APITimeEntry, len(entries))}
+	for i, e := range entries {
+		resp.Entries[i] = newAPITimeEntry(e, now)
+		resp.Total += resp.Entries[i].Duration
+	}
+
+	writeJSON(w, resp)
+}
+
+func (a *API) deleteTimeEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, "Не указан идентификатор записи", http.StatusBadRequest)
+		return
+	}
+
+	err := db.DeleteTimeEntry(r.Context(), id)
+	if errors.Is(err, db.ErrNotFound) {
+		writeError(w, "Запись не найдена", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on deleting time entry from database", "error", err)
+		writeDBError(w, err, "Ошибка удаления записи времени", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, Response{})
+}
+
+func (a *API) TimeTotalsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskID, from, to, ok := timeFilterParams(w, r)
+	if !ok {
+		return
+	}
+
+	totals, err := db.TimeTotals(r.Context(), taskID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "error on getting time totals from database", "error", err)
+		writeDBError(w, err, "Ошибка получения итогов из базы данных", http.StatusInternalServerError)
+		return
+	}
+
+	resp := TimeTotalsResp{Tasks: make([]*APITimeTotal, 0)}
+	var current *APITimeTotal
+	for _, t := range totals {
+		if current == nil || current.TaskID != strconv.FormatInt(t.TaskID, 10) {
+			current = &APITimeTotal{
+				TaskID:      strconv.FormatInt(t.TaskID, 10),
+				Title:       t.Title,
+				Occurrences: make([]*APIOccurrenceTime, 0),
+			}
+			resp.Tasks = append(resp.Tasks, current)
+		}
+		current.Occurrences = append(current.Occurrences, &APIOccurrenceTime{Date: t.Occurrence, Duration: t.Duration})
+		current.Duration += t.Duration
+		resp.Total += t.Duration
+	}
+
+	writeJSON(w, resp)
+}
+
+func timeFilterParams(w http.ResponseWriter, r *http.Request) (taskID, from, to string, ok bool) {
+	q := r.URL.Query()
+	taskID, from, to = q.Get("task_id"), q.Get("from"), q.Get("to")
+
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(DateFormat, d); err != nil {
+			writeError(w, "Некорректная дата в параметрах from/to", http.StatusBadRequest)
+			return "", "", "", false
+		}
+	}
+
+	return taskID, from, to, true
+}
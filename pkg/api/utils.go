@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -15,7 +17,25 @@ func writeJSON(w http.ResponseWriter, data any) {
 	json.NewEncoder(w).Encode(data)
 }
 
-func writeError(w http.ResponseWriter, message string, statusCode int) {
+// writeJSONStatus is writeJSON with a status other than 200; the content type
+// has to be set before the status is sent.
+func writeJSONStatus(w http.ResponseWriter, statusCode int, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(statusCode)
-	writeJSON(w, Response{Error: message})
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, message string, statusCode int) {
+	writeJSONStatus(w, statusCode, Response{Error: message})
+}
+
+// writeDBError reports a failed database call. A query that ran out of time
+// is a 503 whatever the call was, so clients know to retry later.
+func writeDBError(w http.ResponseWriter, err error, message string, statusCode int) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, "База данных не ответила вовремя, повторите запрос позже", http.StatusServiceUnavailable)
+		return
+	}
+	writeError(w, message, statusCode)
 }
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// The /api/v1 handlers take the task ID from the path. Their routes carry
+// the method, so the server answers other methods with 405 and Allow; the
+// legacy /api/task handlers share the same code and read the ID from the
+// query or the body instead.
+
+// taskID reads the {id} path value, which must be a positive integer. On
+// failure it writes the error response and returns false.
+func taskID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, "Некорректный идентификатор задачи", http.StatusBadRequest)
+		return "", false
+	}
+	return strconv.FormatInt(id, 10), true
+}
+
+// CreateTaskV1Handler answers 201 with the address of the new task.
+func (a *API) CreateTaskV1Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := a.addTask(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Location", "/api/v1/tasks/"+strconv.FormatInt(id, 10))
+	writeJSONStatus(w, http.StatusCreated, Response{ID: strconv.FormatInt(id, 10)})
+}
+
+func (a *API) GetTaskV1Handler(w http.ResponseWriter, r *http.Request) {
+	if id, ok := taskID(w, r); ok {
+		a.getTask(w, r, id)
+	}
+}
+
+// UpdateTaskV1Handler accepts the task without an id in the body, or with the
+// same id as in the path.
+func (a *API) UpdateTaskV1Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := taskID(w, r)
+	if !ok {
+		return
+	}
+
+	var apiTask APITask
+	if err := json.NewDecoder(r.Body).Decode(&apiTask); err != nil {
+		writeError(w, "Некорректный формат JSON", http.StatusBadRequest)
+		return
+	}
+	if apiTask.ID != "" && apiTask.ID != id {
+		writeError(w, "Идентификатор в теле запроса не совпадает с адресом задачи", http.StatusBadRequest)
+		return
+	}
+
+	n, _ := strconv.ParseInt(id, 10, 64)
+	a.updateTask(w, r, n, &apiTask)
+}
+
+func (a *API) DeleteTaskV1Handler(w http.ResponseWriter, r *http.Request) {
+	if id, ok := taskID(w, r); ok {
+		a.deleteTask(w, r, id)
+	}
+}
+
+func (a *API) DoneTaskV1Handler(w http.ResponseWriter, r *http.Request) {
+	if id, ok := taskID(w, r); ok {
+		a.doneTask(w, r, id)
+	}
+}
@@ -1,45 +1,369 @@
+// Package config loads the server settings. Every setting has a default and
+// can be set in a YAML file, by a command-line flag and by a TODO_*
+// environment variable. Each of these layers overrides the previous one:
+// defaults < file < flags < environment.
 package config
 
 import (
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/ElenaMask/go_final_project/pkg/snapshot"
 )
 
 const (
-	TODO_PORT   = "TODO_PORT"
-	TODO_DBFILE = "TODO_DBFILE"
+	TODO_CONFIG            = "TODO_CONFIG"
+	TODO_PORT              = "TODO_PORT"
+	TODO_PASSWORD          = "TODO_PASSWORD"
+	TODO_READ_TIMEOUT      = "TODO_READ_TIMEOUT"
+	TODO_WRITE_TIMEOUT     = "TODO_WRITE_TIMEOUT"
+	TODO_IDLE_TIMEOUT      = "TODO_IDLE_TIMEOUT"
+	TODO_WEB_DIR           = "TODO_WEB_DIR"
+	TODO_LOG_FORMAT        = "TODO_LOG_FORMAT"
+	TODO_METRICS_PORT      = "TODO_METRICS_PORT"
+	TODO_READY_MIN_FREE_MB = "TODO_READY_MIN_FREE_MB"
+	TODO_DBFILE            = "TODO_DBFILE"
+	TODO_DB_DRIVER         = "TODO_DB_DRIVER"
+	TODO_DB_DSN            = "TODO_DB_DSN"
+	TODO_DB_TIMEOUT        = "TODO_DB_TIMEOUT"
+	TODO_SHUTDOWN_TIMEOUT  = "TODO_SHUTDOWN_TIMEOUT"
+	TODO_TASKS_LIMIT       = "TODO_TASKS_LIMIT"
+	TODO_TASKS_MAX_LIMIT   = "TODO_TASKS_MAX_LIMIT"
+	TODO_TZ                = "TODO_TZ"
+	TODO_AGENDA_MAX_DAYS   = "TODO_AGENDA_MAX_DAYS"
+	TODO_BACKUP_DIR        = "TODO_BACKUP_DIR"
+	TODO_BACKUP_KEEP       = "TODO_BACKUP_KEEP"
+	TODO_BACKUP_SCHEDULE   = "TODO_BACKUP_SCHEDULE"
+	TODO_NOTIFIER          = "TODO_NOTIFIER"
+	TODO_REMINDER_INTERVAL = "TODO_REMINDER_INTERVAL"
+	TODO_WEBHOOK_URL       = "TODO_WEBHOOK_URL"
+	TODO_SMTP_ADDR         = "TODO_SMTP_ADDR"
+	TODO_SMTP_FROM         = "TODO_SMTP_FROM"
+	TODO_SMTP_TO           = "TODO_SMTP_TO"
+	TODO_SMTP_USER         = "TODO_SMTP_USER"
+	TODO_SMTP_PASSWORD     = "TODO_SMTP_PASSWORD"
 )
 
-var (
-	WebDir = "web"
-	Port   = 7540
-	DBFile = "scheduler.db"
-)
+// Config holds the server settings. Durations are whole seconds, as in the
+// environment variables.
+type Config struct {
+	Port int `yaml:"port"`
+
+	// Password protects the API and the web UI; empty leaves them open.
+	Password string `yaml:"password"`
+
+	// The HTTP server timeouts: reading a request, writing a response and
+	// keeping an idle connection.
+	ReadTimeout  int `yaml:"read_timeout"`
+	WriteTimeout int `yaml:"write_timeout"`
+	IdleTimeout  int `yaml:"idle_timeout"`
+
+	WebDir      string `yaml:"web_dir"`
+	LogFormat   string `yaml:"log_format"`
+	MetricsPort int    `yaml:"metrics_port"`
+
+	// ReadyMinFreeMB is the free space next to DBFile below which the server
+	// is not ready.
+	ReadyMinFreeMB int `yaml:"ready_min_free_mb"`
+
+	// DBDriver is "sqlite" for the DBFile database or "postgres" for the
+	// database at DBDSN.
+	DBDriver string `yaml:"db_driver"`
+	DBFile   string `yaml:"dbfile"`
+	DBDSN    string `yaml:"db_dsn"`
 
-func init() {
-	Port = getIntEnvOrDefault(TODO_PORT, Port)
-	DBFile = getEnvOrDefault(TODO_DBFILE, DBFile)
+	// DBTimeout limits each database query of a request. It must be below
+	// WriteTimeout, so a slow query still gets a response.
+	DBTimeout int `yaml:"db_timeout"`
+
+	// ShutdownTimeout is how long requests in flight may finish after SIGINT
+	// or SIGTERM. It stays under the 10 seconds Docker waits before SIGKILL,
+	// leaving time to close the database.
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
+
+	TasksLimit    int `yaml:"tasks_limit"`
+	MaxTasksLimit int `yaml:"tasks_max_limit"`
+	AgendaMaxDays int `yaml:"agenda_max_days"`
+
+	// TZ defines what "today" is for date calculations and task views; empty
+	// means the local time zone. Location is loaded from it by Validate.
+	TZ       string         `yaml:"tz"`
+	Location *time.Location `yaml:"-"`
+
+	// BackupSchedule is a daily time such as "03:00" or an interval such as
+	// "6h"; empty disables scheduled snapshots.
+	BackupDir      string `yaml:"backup_dir"`
+	BackupKeep     int    `yaml:"backup_keep"`
+	BackupSchedule string `yaml:"backup_schedule"`
+
+	Notifier         string `yaml:"notifier"`
+	ReminderInterval int    `yaml:"reminder_interval"`
+	WebhookURL       string `yaml:"webhook_url"`
+	SMTPAddr         string `yaml:"smtp_addr"`
+	SMTPFrom         string `yaml:"smtp_from"`
+	SMTPTo           string `yaml:"smtp_to"`
+	SMTPUser         string `yaml:"smtp_user"`
+	SMTPPassword     string `yaml:"smtp_password"`
 }
 
-func getIntEnvOrDefault(key string, def int) int {
-	valStr := os.Getenv(key)
-	if valStr == "" {
-		return def
+func Default() *Config {
+	return &Config{
+		Port:             7540,
+		ReadTimeout:      5,
+		WriteTimeout:     10,
+		IdleTimeout:      15,
+		LogFormat:        "text",
+		ReadyMinFreeMB:   100,
+		DBDriver:         "sqlite",
+		DBFile:           "scheduler.db",
+		DBTimeout:        5,
+		ShutdownTimeout:  8,
+		TasksLimit:       50,
+		MaxTasksLimit:    500,
+		AgendaMaxDays:    366,
+		Location:         time.Local,
+		BackupDir:        "backups",
+		BackupKeep:       7,
+		Notifier:         "log",
+		ReminderInterval: 60,
 	}
+}
+
+// setting ties a field to its environment variable and flag. The flag name
+// is the YAML key with dashes.
+type setting struct {
+	key, env, help string
+	// ptr is a *int or a *string field of the Config.
+	ptr any
+}
 
-	val, err := strconv.Atoi(valStr)
+func (c *Config) settings() []setting {
+	return []setting{
+		{"port", TODO_PORT, "HTTP port", &c.Port},
+		{"password", TODO_PASSWORD, "password for the API and the web UI, empty for none", &c.Password},
+		{"read_timeout", TODO_READ_TIMEOUT, "seconds to read a request", &c.ReadTimeout},
+		{"write_timeout", TODO_WRITE_TIMEOUT, "seconds to write a response", &c.WriteTimeout},
+		{"idle_timeout", TODO_IDLE_TIMEOUT, "seconds an idle connection is kept open", &c.IdleTimeout},
+		{"web_dir", TODO_WEB_DIR, "directory to serve the web files from instead of the built-in ones", &c.WebDir},
+		{"log_format", TODO_LOG_FORMAT, "log format: text or json", &c.LogFormat},
+		{"metrics_port", TODO_METRICS_PORT, "separate port for /metrics, 0 serves it on the HTTP port", &c.MetricsPort},
+		{"ready_min_free_mb", TODO_READY_MIN_FREE_MB, "free megabytes next to the SQLite file required by /readyz", &c.ReadyMinFreeMB},
+		{"db_driver", TODO_DB_DRIVER, "task storage: sqlite or postgres", &c.DBDriver},
+		{"dbfile", TODO_DBFILE, "SQLite database file", &c.DBFile},
+		{"db_dsn", TODO_DB_DSN, "PostgreSQL connection string", &c.DBDSN},
+		{"db_timeout", TODO_DB_TIMEOUT, "seconds a database query of a request may take, 0 for no limit", &c.DBTimeout},
+		{"shutdown_timeout", TODO_SHUTDOWN_TIMEOUT, "seconds requests may finish after SIGINT or SIGTERM", &c.ShutdownTimeout},
+		{"tasks_limit", TODO_TASKS_LIMIT, "default page size of task lists", &c.TasksLimit},
+		{"tasks_max_limit", TODO_TASKS_MAX_LIMIT, "largest page size of task lists", &c.MaxTasksLimit},
+		{"agenda_max_days", TODO_AGENDA_MAX_DAYS, "longest agenda range in days", &c.AgendaMaxDays},
+		{"tz", TODO_TZ, "time zone that defines today, local by default", &c.TZ},
+		{"backup_dir", TODO_BACKUP_DIR, "directory for database snapshots", &c.BackupDir},
+		{"backup_keep", TODO_BACKUP_KEEP, "snapshots to keep, 0 keeps all", &c.BackupKeep},
+		{"backup_schedule", TODO_BACKUP_SCHEDULE, "snapshot time such as 03:00 or interval such as 6h", &c.BackupSchedule},
+		{"notifier", TODO_NOTIFIER, "reminder delivery: log, webhook or smtp", &c.Notifier},
+		{"reminder_interval", TODO_REMINDER_INTERVAL, "seconds between reminder checks", &c.ReminderInterval},
+		{"webhook_url", TODO_WEBHOOK_URL, "URL the webhook notifier posts to", &c.WebhookURL},
+		{"smtp_addr", TODO_SMTP_ADDR, "SMTP server host:port", &c.SMTPAddr},
+		{"smtp_from", TODO_SMTP_FROM, "sender of reminder emails", &c.SMTPFrom},
+		{"smtp_to", TODO_SMTP_TO, "comma-separated recipients of reminder emails", &c.SMTPTo},
+		{"smtp_user", TODO_SMTP_USER, "SMTP user", &c.SMTPUser},
+		{"smtp_password", TODO_SMTP_PASSWORD, "SMTP password", &c.SMTPPassword},
+	}
+}
+
+func (s setting) set(value string) error {
+	switch p := s.ptr.(type) {
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*p = n
+	case *string:
+		*p = value
+	}
+	return nil
+}
+
+// Flags are the command-line flags of the settings, plus -config.
+type Flags struct {
+	file *string
+	set  map[string]string
+}
+
+// NewFlags registers the flags on fs. Their values are applied by Load, once
+// the file named by -config has been read.
+func NewFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{
+		file: fs.String("config", "", "YAML configuration file, "+TODO_CONFIG+" by default"),
+		set:  make(map[string]string),
+	}
+	for _, s := range Default().settings() {
+		name := strings.ReplaceAll(s.key, "_", "-")
+		fs.Func(name, s.help+" ("+s.env+")", func(value string) error {
+			f.set[s.key] = value
+			return nil
+		})
+	}
+	return f
+}
+
+// Load builds the configuration from the defaults, the file, the flags that
+// were set and the environment read by getenv, in this order, and validates
+// it.
+func (f *Flags) Load(getenv func(string) string) (*Config, error) {
+	c := Default()
+
+	file := *f.file
+	if file == "" {
+		file = getenv(TODO_CONFIG)
+	}
+	if file != "" {
+		if err := c.readFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range c.settings() {
+		if value, ok := f.set[s.key]; ok {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("flag -%s: %w", strings.ReplaceAll(s.key, "_", "-"), err)
+			}
+		}
+	}
+	for _, s := range c.settings() {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				return nil, fmt.Errorf("variable %s: %w", s.env, err)
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		log.Printf("Variable %s: can not parse int, use default value: %d", key, def)
-		return def
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid value at once and loads Location.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Port > 0 && c.Port <= 65535, "port %d is out of range 1-65535", c.Port)
+	check(c.MetricsPort >= 0 && c.MetricsPort <= 65535, "metrics_port %d is out of range 0-65535", c.MetricsPort)
+	check(c.MetricsPort != c.Port, "metrics_port must differ from port")
+	check(c.LogFormat == "text" || c.LogFormat == "json", "log_format must be text or json, not %q", c.LogFormat)
+	check(c.ReadyMinFreeMB >= 0, "ready_min_free_mb must not be negative")
+	check(c.ReadTimeout > 0, "read_timeout must be positive")
+	check(c.WriteTimeout > 0, "write_timeout must be positive")
+	check(c.IdleTimeout > 0, "idle_timeout must be positive")
+
+	switch c.DBDriver {
+	case "sqlite":
+		check(c.DBFile != "", "dbfile is empty")
+	case "postgres":
+		check(c.DBDSN != "", "db_dsn is required for the postgres driver")
+		check(c.BackupSchedule == "", "snapshots are only supported with the sqlite driver")
+	default:
+		check(false, "db_driver must be sqlite or postgres, not %q", c.DBDriver)
 	}
+	check(c.DBTimeout >= 0, "db_timeout must not be negative")
+	check(c.DBTimeout < c.WriteTimeout, "db_timeout %d must be less than write_timeout %d", c.DBTimeout, c.WriteTimeout)
+	check(c.ShutdownTimeout >= 0, "shutdown_timeout must not be negative")
 
-	return val
+	check(c.TasksLimit > 0, "tasks_limit must be positive")
+	check(c.MaxTasksLimit > 0, "tasks_max_limit must be positive")
+	check(c.AgendaMaxDays > 0, "agenda_max_days must be positive")
+
+	c.Location = time.Local
+	if c.TZ != "" {
+		loc, err := time.LoadLocation(c.TZ)
+		check(err == nil, "tz %q is not a known time zone", c.TZ)
+		if err == nil {
+			c.Location = loc
+		}
+	}
+
+	check(c.BackupKeep >= 0, "backup_keep must not be negative")
+	if c.BackupSchedule != "" {
+		_, err := snapshot.ParseSchedule(c.BackupSchedule)
+		check(err == nil, "backup_schedule: %v", err)
+	}
+
+	check(c.ReminderInterval > 0, "reminder_interval must be positive")
+	switch c.Notifier {
+	case "log":
+	case "webhook":
+		check(c.WebhookURL != "", "webhook_url is required for the webhook notifier")
+	case "smtp":
+		check(c.SMTPAddr != "" && c.SMTPFrom != "" && c.SMTPTo != "",
+			"smtp_addr, smtp_from and smtp_to are required for the smtp notifier")
+	default:
+		check(false, "notifier must be log, webhook or smtp, not %q", c.Notifier)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
 }
 
-func getEnvOrDefault(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// Write prints the configuration as YAML that Load accepts, with passwords
+// masked.
+func (c *Config) Write(w io.Writer) error {
+	masked := *c
+	if masked.SMTPPassword != "" {
+		masked.SMTPPassword = "***"
+	}
+	if masked.Password != "" {
+		masked.Password = "***"
+	}
+	masked.DBDSN = maskDSN(masked.DBDSN)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&masked); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return enc.Close()
+}
+
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('[^']*'|\S+)`)
+
+// maskDSN hides the password in both URL and key=value connection strings.
+func maskDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		return u.Redacted()
 	}
-	return def
+	return dsnPassword.ReplaceAllString(dsn, "${1}***")
 }
@@ -0,0 +1,450 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	BackupFormat  = "go_final_project-backup"
+	BackupVersion = 2
+)
+
+// ErrBackupFormat wraps every problem with the contents of a backup, as
+// opposed to database errors.
+var ErrBackupFormat = errors.New("invalid backup")
+
+// backupTable describes how a table is dumped. TaskColumn names the column
+// referring to scheduler.id, which has to be remapped when merging. Added
+// lists the columns that dumps of older versions lack.
+type backupTable struct {
+	Name       string
+	Table      string
+	Columns    []string
+	TaskColumn string
+	Added      map[string]addedColumn
+}
+
+// addedColumn is a column that appeared in dump Version; older dumps restore
+// it as Default.
+type addedColumn struct {
+	Version int
+	Default any
+}
+
+// Tasks go first, so that rows referring to them can be remapped on merge.
+// The search index is not dumped, it is maintained by triggers on restore.
+var backupTables = []backupTable{
+	{Name: "tasks", Table: "scheduler", Columns: []string{"id", "date", "title", "comment", "repeat"}},
+	{Name: "reminders", Table: "reminders", Columns: []string{"id", "task_id", "offset_days", "time", "sent_for", "attempts", "last_error", "retry_at", "failed_for", "created_at"}, TaskColumn: "task_id",
+		Added: map[string]addedColumn{"retry_at": {2, ""}, "failed_for": {2, ""}, "created_at": {2, ""}}},
+	{Name: "time_entries", Table: "time_entries", Columns: []string{"id", "task_id", "occurrence", "day", "started_at", "stopped_at", "duration", "comment"}, TaskColumn: "task_id"},
+	{Name: "task_sources", Table: "task_sources", Columns: []string{"uid", "task_id"}, TaskColumn: "task_id"},
+	{Name: "settings", Table: "settings", Columns: []string{"name", "value"}},
+}
+
+// RestoreStats counts restored and skipped rows per table.
+type RestoreStats struct {
+	Restored map[string]int `json:"restored"`
+	Skipped  map[string]int `json:"skipped"`
+}
+
+// Backup writes all tables as JSON, read in a single transaction so that the
+// dump is consistent:
+//
+//	{"format": "...", "version": 1, "created_at": "...", "tables": {"tasks": [{...}], ...}}
+//
+// The dump is streamed into a temporary file and only copied to w once the
+// transaction is over, so that a slow reader does not keep writers waiting
+// and a large database is not held in memory.
+func Backup(ctx context.Context, w io.Writer, now time.Time) error {
+	defer observe("Backup")()
+	f, err := os.CreateTemp("", "scheduler-backup-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := dump(ctx, f, now); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind backup file: %w", err)
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return nil
+}
+
+func dump(ctx context.Context, f *os.File, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin backup: %w", err)
+	}
+	defer tx.Rollback()
+
+	buf := bufio.NewWriter(f)
+	fmt.Fprintf(buf, `{"format":%q,"version":%d,"created_at":%q,"tables":{`, BackupFormat, BackupVersion, now.Format(time.RFC3339))
+	for i, t := range backupTables {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(buf, "\n%q:[", t.Name)
+		if err := backupRows(ctx, tx, buf, t); err != nil {
+			return err
+		}
+		buf.WriteString("]")
+	}
+	buf.WriteString("}}\n")
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+	return nil
+}
+
+func backupRows(ctx context.Context, tx *sql.Tx, w *bufio.Writer, t backupTable) error {
+	query := fmt.Sprintf(`SELECT %s FROM %s ORDER BY rowid`, strings.Join(t.Columns, ", "), t.Table)
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", t.Table, err)
+	}
+	defer rows.Close()
+
+	values := make([]any, len(t.Columns))
+	ptrs := make([]any, len(t.Columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	first := true
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("failed to scan %s row: %w", t.Table, err)
+		}
+		row := make(map[string]any, len(t.Columns))
+		for i, c := range t.Columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[c] = values[i]
+		}
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s row: %w", t.Table, err)
+		}
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+		w.WriteString("\n")
+		w.Write(data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over %s rows: %w", t.Table, err)
+	}
+	return nil
+}
+
+// Restore reads a dump written by Backup and applies it in one transaction.
+// In replace mode all tables are emptied first and rows keep their ids. In
+// merge mode the existing data is kept: tasks, reminders and time entries get
+// new ids, existing settings and import UIDs win, and rows that refer to a
+// task missing from the dump are skipped.
+//
+// The dump is first copied into a temporary file and checked there in one
+// pass, so that a slow upload does not keep other writers waiting; the
+// transaction then applies the rows as they are decoded from the file.
+func Restore(ctx context.Context, r io.Reader, merge bool) (*RestoreStats, error) {
+	defer observe("Restore")()
+	f, err := os.CreateTemp("", "scheduler-restore-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create restore file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// A failed upload is not a problem of the file.
+	if _, err := io.Copy(f, r); err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	if err := decodeBackup(f, &restorer{}); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin restore: %w", err)
+	}
+	defer tx.Rollback()
+
+	if !merge {
+		for i := len(backupTables) - 1; i >= 0; i-- {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+backupTables[i].Table); err != nil {
+				return nil, fmt.Errorf("failed to clear %s: %w", backupTables[i].Table, err)
+			}
+		}
+	}
+	rs := &restorer{
+		ctx:   ctx,
+		tx:    tx,
+		merge: merge,
+		tasks: make(map[int64]int64),
+		stats: &RestoreStats{Restored: make(map[string]int), Skipped: make(map[string]int)},
+	}
+	if err := decodeBackup(f, rs); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return rs.stats, nil
+}
+
+// decodeBackup reads the dump in f from the start. Without a transaction rs
+// only checks the rows, otherwise it restores each row once it is decoded.
+func decodeBackup(f *os.File, rs *restorer) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind restore file: %w", err)
+	}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	dec.UseNumber()
+	return rs.read(dec)
+}
+
+type restorer struct {
+	ctx     context.Context
+	tx      *sql.Tx
+	merge   bool
+	version int
+	// tasks maps task ids of the dump to the ids they got in merge mode.
+	tasks map[int64]int64
+	stats *RestoreStats
+}
+
+func formatError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrBackupFormat, fmt.Sprintf(format, args...))
+}
+
+func (rs *restorer) read(dec *json.Decoder) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	var format string
+	for dec.More() {
+		key, err := readKey(dec)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "format":
+			if err := dec.Decode(&format); err != nil {
+				return formatError("format: %v", err)
+			}
+			if format != BackupFormat {
+				return formatError("unknown format %q", format)
+			}
+		case "version":
+			if err := dec.Decode(&rs.version); err != nil {
+				return formatError("version: %v", err)
+			}
+			if rs.version < 1 || rs.version > BackupVersion {
+				return formatError("unsupported version %d, this server supports up to %d", rs.version, BackupVersion)
+			}
+		case "tables":
+			// Rows are checked while they are read, so the header has to
+			// come first.
+			if format == "" || rs.version == 0 {
+				return formatError("format and version must precede tables")
+			}
+			if err := rs.readTables(dec); err != nil {
+				return err
+			}
+		default:
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return formatError("%s: %v", key, err)
+			}
+		}
+	}
+
+	if rs.version == 0 {
+		return formatError("missing version")
+	}
+	return expectDelim(dec, '}')
+}
+
+func (rs *restorer) readTables(dec *json.Decoder) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		name, err := readKey(dec)
+		if err != nil {
+			return err
+		}
+		var table *backupTable
+		for i := range backupTables {
+			if backupTables[i].Name == name {
+				table = &backupTables[i]
+			}
+		}
+		if table == nil {
+			return formatError("unknown table %q", name)
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			var row map[string]any
+			if err := dec.Decode(&row); err != nil {
+				return formatError("%s: %v", name, err)
+			}
+			values, err := rowValues(table, row, rs.version)
+			if err != nil {
+				return err
+			}
+			if rs.tx != nil {
+				if err := rs.restoreRow(table, values); err != nil {
+					return err
+				}
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// rowValues checks a row of a dump of the given version and returns its
+// values in the order of the table columns.
+func rowValues(t *backupTable, row map[string]any, version int) ([]any, error) {
+	values := make([]any, 0, len(t.Columns))
+	for c := range row {
+		if !slices.Contains(t.Columns, c) {
+			return nil, formatError("%s: unknown column %q", t.Name, c)
+		}
+	}
+	for _, c := range t.Columns {
+		v, ok := row[c]
+		if added, isNew := t.Added[c]; !ok && isNew && version < added.Version {
+			values = append(values, added.Default)
+			continue
+		}
+		if !ok {
+			return nil, formatError("%s: missing column %q", t.Name, c)
+		}
+		switch v := v.(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil {
+				return nil, formatError("%s: %s must be an integer", t.Name, c)
+			}
+			values = append(values, n)
+		case string, nil:
+			values = append(values, v)
+		default:
+			return nil, formatError("%s: unexpected value of %s", t.Name, c)
+		}
+	}
+	return values, nil
+}
+
+func (rs *restorer) restoreRow(t *backupTable, values []any) error {
+	columns := t.Columns
+	insert := "INSERT"
+	var oldID int64
+	if rs.merge {
+		if t.TaskColumn != "" {
+			i := slices.Index(columns, t.TaskColumn)
+			taskID, _ := values[i].(int64)
+			newID, ok := rs.tasks[taskID]
+			if !ok {
+				rs.stats.Skipped[t.Name]++
+				return nil
+			}
+			values[i] = newID
+		}
+		// Ids are assigned anew; rows keyed by name or UID keep the
+		// existing ones, as does an already running timer.
+		if columns[0] == "id" {
+			oldID, _ = values[0].(int64)
+			columns, values = columns[1:], values[1:]
+		} else {
+			insert = "INSERT OR IGNORE"
+		}
+		if t.Table == "time_entries" {
+			insert = "INSERT OR IGNORE"
+		}
+	}
+
+	query := fmt.Sprintf(`%s INTO %s (%s) VALUES (?%s)`, insert, t.Table,
+		strings.Join(columns, ", "), strings.Repeat(", ?", len(columns)-1))
+	res, err := rs.tx.ExecContext(rs.ctx, query, values...)
+	if err != nil {
+		var sqliteErr *sqlite.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_CONSTRAINT {
+			return formatError("%s: %v", t.Name, err)
+		}
+		return fmt.Errorf("failed to restore %s row: %w", t.Name, err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected after restore: %w", err)
+	}
+	if count == 0 {
+		rs.stats.Skipped[t.Name]++
+		return nil
+	}
+	rs.stats.Restored[t.Name]++
+
+	if rs.merge && t.Table == "scheduler" {
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get restored task id: %w", err)
+		}
+		rs.tasks[oldID] = id
+	}
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return formatError("%v", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return formatError("expected %q, got %v", delim, tok)
+	}
+	return nil
+}
+
+func readKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", formatError("%v", err)
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", formatError("expected a key, got %v", tok)
+	}
+	return key, nil
+}
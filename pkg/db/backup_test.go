@@ -0,0 +1,136 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreDoesNotBlockWhileReading(t *testing.T) {
+	require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	_, err := AddTask(t.Context(), &Task{Date: "20261019", Title: "В копии"})
+	require.NoError(t, err)
+	var dump bytes.Buffer
+	require.NoError(t, Backup(t.Context(), &dump, time.Now()))
+
+	// The upload stalls halfway; other writers must not wait for it.
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := Restore(t.Context(), pr, false)
+		done <- err
+	}()
+	half := dump.Len() / 2
+	_, err = pw.Write(dump.Bytes()[:half])
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	_, err = AddTask(ctx, &Task{Date: "20261019", Title: "Во время загрузки"})
+	require.NoError(t, err)
+
+	_, err = pw.Write(dump.Bytes()[half:])
+	require.NoError(t, err)
+	require.NoError(t, pw.Close())
+	require.NoError(t, <-done)
+
+	tasks, err := Tasks(t.Context(), Cursor{}, -1)
+	require.NoError(t, err)
+	if assert.Len(t, tasks, 1, "replace keeps only the dump") {
+		assert.Equal(t, "В копии", tasks[0].Title)
+	}
+}
+
+func TestBackupDoesNotBlockWhileWriting(t *testing.T) {
+	require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	_, err := AddTask(t.Context(), &Task{Date: "20261019", Title: "В копии"})
+	require.NoError(t, err)
+
+	// Nobody reads the download yet; other writers must not wait for it.
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Backup(t.Context(), pw, time.Now())
+		pw.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	_, err = AddTask(ctx, &Task{Date: "20261019", Title: "Во время выгрузки"})
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(pr)
+	require.NoError(t, err)
+	require.NoError(t, <-done)
+	assert.Contains(t, string(data), "В копии")
+}
+
+func TestRestoreReadError(t *testing.T) {
+	require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	_, err := AddTask(t.Context(), &Task{Date: "20261019", Title: "До восстановления"})
+	require.NoError(t, err)
+	var dump bytes.Buffer
+	require.NoError(t, Backup(t.Context(), &dump, time.Now()))
+
+	// An upload that breaks off is reported as such, not as a bad file, and
+	// the database is left as it was.
+	broken := errors.New("connection reset")
+	body := io.MultiReader(bytes.NewReader(dump.Bytes()[:dump.Len()/2]), iotest.ErrReader(broken))
+	_, err = Restore(t.Context(), body, false)
+	assert.ErrorIs(t, err, broken)
+	assert.NotErrorIs(t, err, ErrBackupFormat)
+
+	tasks, err := Tasks(t.Context(), Cursor{}, -1)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+}
+
+func TestBackupReminderState(t *testing.T) {
+	require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	taskID, err := AddTask(t.Context(), &Task{Date: "20261020", Title: "С напоминанием"})
+	require.NoError(t, err)
+	id, err := AddReminder(t.Context(), &Reminder{TaskID: taskID, Time: "09:00", CreatedAt: "2026-10-19 12:00"})
+	require.NoError(t, err)
+	require.NoError(t, MarkReminderFailed(t.Context(), id, "unavailable", "2026-10-20 09:02"))
+	require.NoError(t, GiveUpReminder(t.Context(), id, "20261013", "unavailable"))
+	want, err := GetReminders(t.Context(), "1")
+	require.NoError(t, err)
+
+	var dump bytes.Buffer
+	require.NoError(t, Backup(t.Context(), &dump, time.Now()))
+	_, err = Restore(t.Context(), &dump, false)
+	require.NoError(t, err)
+	got, err := GetReminders(t.Context(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// Version 1 dumps had no retry state; those columns get their defaults.
+	v1 := `{"format":"go_final_project-backup","version":1,"tables":{
+"tasks":[{"id":1,"date":"20261020","title":"Из старой копии","comment":"","repeat":""}],
+"reminders":[{"id":1,"task_id":1,"offset_days":0,"time":"09:00","sent_for":"","attempts":2,"last_error":"timeout"}]}}`
+	_, err = Restore(t.Context(), strings.NewReader(v1), false)
+	require.NoError(t, err)
+	got, err = GetReminders(t.Context(), "1")
+	require.NoError(t, err)
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, 2, got[0].Attempts)
+		assert.Empty(t, got[0].RetryAt)
+		assert.Empty(t, got[0].FailedFor)
+		assert.Empty(t, got[0].CreatedAt)
+	}
+
+	// Version 2 dumps must have them.
+	v2 := strings.Replace(v1, `"version":1`, `"version":2`, 1)
+	_, err = Restore(t.Context(), strings.NewReader(v2), false)
+	assert.ErrorIs(t, err, ErrBackupFormat)
+	assert.ErrorContains(t, err, `missing column "retry_at"`)
+}
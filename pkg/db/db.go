@@ -1,42 +1,90 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-const schema = `
-CREATE TABLE IF NOT EXISTS scheduler (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    date CHAR(8) NOT NULL DEFAULT "" CHECK (LENGTH(date) = 8),
-    title VARCHAR(255) NOT NULL DEFAULT "" CHECK (LENGTH(title) <= 255),
-    comment TEXT NOT NULL DEFAULT "",
-    repeat VARCHAR(128) NOT NULL DEFAULT "" CHECK (LENGTH(repeat) <= 128)
-);
+var db *sql.DB
 
-CREATE INDEX IF NOT EXISTS idx_scheduler_date ON scheduler (date);
-`
+// QueryTimeout limits each query made on behalf of a request, so that a slow
+// query fails with context.DeadlineExceeded instead of holding a connection;
+// zero means no limit. Backups, restores, imports and snapshots run for as
+// long as their caller's context allows.
+var QueryTimeout time.Duration
 
-var db *sql.DB
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, QueryTimeout)
+}
 
-func Init(dbFile string) error {
-	_, err := os.Stat(dbFile)
-	install := err != nil
+// busyTimeout is how long a statement waits for a lock held by another
+// connection before it fails with SQLITE_BUSY.
+const busyTimeout = 5 * time.Second
 
-	db, err = sql.Open("sqlite", dbFile)
+// Init opens dbFile in WAL mode, where readers and a writer do not block each
+// other, and applies pending migrations. The pragmas are part of the DSN, so
+// that every connection of the pool gets them.
+func Init(dbFile string) error {
+	dsn := fmt.Sprintf("%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)", dbFile, busyTimeout.Milliseconds())
+	var err error
+	db, err = sql.Open("sqlite", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if install {
-		_, err = db.Exec(schema)
-		if err != nil {
-			return fmt.Errorf("error in creating schema: %w", err)
-		}
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	return migrate(db, sqliteDialect, migrations)
+}
+
+func Ping(ctx context.Context) error {
+	defer observe("Ping")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+// SchemaVersion returns the latest migration of the database. It fails with
+// ErrSchemaOutdated or ErrSchemaTooNew if the database does not have exactly
+// the migrations of this server.
+func SchemaVersion(ctx context.Context) (int, error) {
+	defer observe("SchemaVersion")()
+	migrations, err := Migrations()
+	if err != nil {
+		return 0, err
+	}
+	return checkSchema(ctx, db, migrations)
+}
+
+// Snapshot writes a consistent copy of the live database to path, which must
+// not exist yet. VACUUM INTO reads in a single transaction, so writers are not
+// blocked for longer than one copy takes and the copy is never half-written.
+func Snapshot(ctx context.Context, path string) error {
+	defer observe("Snapshot")()
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
 	}
+	return nil
+}
 
+// Close moves everything from the write-ahead log into the database file and
+// closes the database, so the file alone is complete once the server exits.
+func Close() error {
+	if _, err := db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
 	return nil
 }
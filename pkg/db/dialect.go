@@ -0,0 +1,79 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// dialect is what differs in the SQL the package sends to SQLite and to
+// Postgres. Queries are written with ? placeholders and rewritten by bind.
+type dialect struct {
+	bind func(query string) string
+	// like is the case-insensitive LIKE operator.
+	like string
+	// textCond is the condition for a task that matches a full-text term.
+	textCond func(t QueryTerm) (string, []any)
+	// lockMigrations, if set, runs first in every migration transaction.
+	lockMigrations string
+}
+
+var sqliteDialect = dialect{
+	bind: func(query string) string { return query },
+	like: "LIKE",
+	textCond: func(t QueryTerm) (string, []any) {
+		return "id IN (SELECT rowid FROM scheduler_search WHERE scheduler_search MATCH ?)", []any{t.ftsExpr()}
+	},
+}
+
+// Postgres has no full-text index here: text terms are substring matches
+// with ILIKE over title and comment with "ё" folded like the query.
+var postgresDialect = dialect{
+	bind:           bindDollar,
+	like:           "ILIKE",
+	textCond:       ilikeCond,
+	lockMigrations: `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`,
+}
+
+// bindDollar numbers the ? placeholders of a query as $1, $2, ... and leaves
+// question marks inside string literals alone.
+func bindDollar(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern is the LIKE pattern matching s anywhere in a value.
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+const (
+	foldedTitle   = "translate(title, 'ёЁ', 'еЕ')"
+	foldedComment = "translate(comment, 'ёЁ', 'еЕ')"
+)
+
+func ilikeCond(t QueryTerm) (string, []any) {
+	pattern := containsPattern(t.Value)
+	switch t.Field {
+	case FieldTitle:
+		return foldedTitle + " ILIKE ?", []any{pattern}
+	case FieldComment:
+		return foldedComment + " ILIKE ?", []any{pattern}
+	default:
+		return "(" + foldedTitle + " ILIKE ? OR " + foldedComment + " ILIKE ?)", []any{pattern, pattern}
+	}
+}
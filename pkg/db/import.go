@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ImportedTask is a task together with the UID of its calendar entry. UID may
+// be empty, then the task is always created.
+type ImportedTask struct {
+	UID     string
+	Task    Task
+	Created bool
+}
+
+// TaskIDByUID returns the id of the task imported with the UID, or 0.
+func TaskIDByUID(ctx context.Context, uid string) (int64, error) {
+	defer observe("TaskIDByUID")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	err := db.QueryRowContext(ctx, `SELECT task_id FROM task_sources WHERE uid = ?`, uid).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get task by uid: %w", err)
+	}
+	return id, nil
+}
+
+// ImportTasks creates the tasks or updates those imported before with the
+// same UID, all in one transaction. Task IDs and Created are filled in.
+func ImportTasks(ctx context.Context, tasks []*ImportedTask) error {
+	defer observe("ImportTasks")()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range tasks {
+		var id int64
+		if t.UID != "" {
+			err = tx.QueryRowContext(ctx, `SELECT task_id FROM task_sources WHERE uid = ?`, t.UID).Scan(&id)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to get task by uid: %w", err)
+			}
+		}
+
+		if id != 0 {
+			_, err = tx.ExecContext(ctx, `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`,
+				t.Task.Date, t.Task.Title, t.Task.Comment, t.Task.Repeat, id)
+			if err != nil {
+				return fmt.Errorf("failed to update imported task: %w", err)
+			}
+			t.Task.ID = id
+			continue
+		}
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`,
+			t.Task.Date, t.Task.Title, t.Task.Comment, t.Task.Repeat)
+		if err != nil {
+			return fmt.Errorf("failed to add imported task: %w", err)
+		}
+		if t.Task.ID, err = res.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to get imported task id: %w", err)
+		}
+		t.Created = true
+
+		if t.UID != "" {
+			_, err = tx.ExecContext(ctx, `INSERT INTO task_sources (uid, task_id) VALUES (?, ?)`, t.UID, t.Task.ID)
+			if err != nil {
+				return fmt.Errorf("failed to save task uid: %w", err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import: %w", err)
+	}
+	return nil
+}
+
+// SaveTasks adds tasks without an id and updates the others in one
+// transaction, so either all of them are saved or none.
+func SaveTasks(ctx context.Context, tasks []*Task) (created, updated int, err error) {
+	defer observe("SaveTasks")()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin saving tasks: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range tasks {
+		if t.ID == 0 {
+			res, err := tx.ExecContext(ctx, `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`,
+				t.Date, t.Title, t.Comment, t.Repeat)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to add task: %w", err)
+			}
+			if t.ID, err = res.LastInsertId(); err != nil {
+				return 0, 0, fmt.Errorf("failed to get task id: %w", err)
+			}
+			created++
+			continue
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`,
+			t.Date, t.Title, t.Comment, t.Repeat, t.ID)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to update task: %w", err)
+		}
+		count, err := res.RowsAffected()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get rows affected after update: %w", err)
+		}
+		if count == 0 {
+			return 0, 0, fmt.Errorf("task with id %d not found", t.ID)
+		}
+		updated++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit tasks: %w", err)
+	}
+	return created, updated, nil
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MemoryStore is a TaskStore that keeps tasks in memory. It behaves like the
+// SQLite store, including the column checks, id assignment and full-text
+// ranking, so that handlers can be tested without a database file. Search
+// ranks may differ from SQLite in the last bit, as its log() is not Go's.
+// Nothing blocks, so contexts are not checked.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	tasks  map[int64]Task
+	lastID int64
+}
+
+var _ TaskStore = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tasks: make(map[int64]Task)}
+}
+
+// checkTask mirrors the CHECK constraints of the scheduler table.
+func checkTask(task *Task) error {
+	switch {
+	case utf8.RuneCountInString(task.Date) != 8:
+		return fmt.Errorf("CHECK constraint failed: LENGTH(date) = 8")
+	case utf8.RuneCountInString(task.Title) > 255:
+		return fmt.Errorf("CHECK constraint failed: LENGTH(title) <= 255")
+	case utf8.RuneCountInString(task.Repeat) > 128:
+		return fmt.Errorf("CHECK constraint failed: LENGTH(repeat) <= 128")
+	}
+	return nil
+}
+
+// parseID converts an id the way SQLite compares text with an INTEGER
+// column: "7", " 7" and "7.0" all refer to task 7.
+func parseID(id string) (int64, bool) {
+	id = strings.TrimSpace(id)
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return n, true
+	}
+	f, err := strconv.ParseFloat(id, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+func (s *MemoryStore) AddTask(_ context.Context, task *Task) (int64, error) {
+	if err := checkTask(task); err != nil {
+		return 0, fmt.Errorf("failed to add task: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	t := *task
+	t.ID = s.lastID
+	s.tasks[t.ID] = t
+	return t.ID, nil
+}
+
+func (s *MemoryStore) GetTask(_ context.Context, id string) (*Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := parseID(id)
+	t, found := s.tasks[n]
+	if !ok || !found {
+		return nil, fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+	return &t, nil
+}
+
+func (s *MemoryStore) UpdateTask(_ context.Context, task *Task) error {
+	if err := checkTask(task); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[task.ID]; !ok {
+		return fmt.Errorf("incorrect id for updating task: %w", ErrNotFound)
+	}
+	s.tasks[task.ID] = *task
+	return nil
+}
+
+func (s *MemoryStore) DeleteTask(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := parseID(id)
+	if _, found := s.tasks[n]; !ok || !found {
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+	delete(s.tasks, n)
+	return nil
+}
+
+func (s *MemoryStore) UpdateDate(_ context.Context, next string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := parseID(id)
+	t, found := s.tasks[n]
+	if !ok || !found {
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+	t.Date = next
+	if err := checkTask(&t); err != nil {
+		return fmt.Errorf("failed to update task date: %w", err)
+	}
+	s.tasks[n] = t
+	return nil
+}
+
+func (s *MemoryStore) Tasks(_ context.Context, after Cursor, limit int) ([]*Task, error) {
+	return s.list(nil, after, limit), nil
+}
+
+func (s *MemoryStore) CountTasks(_ context.Context) (int, error) {
+	return s.count(nil), nil
+}
+
+func (s *MemoryStore) GetTasksByDate(_ context.Context, date string, after Cursor, limit int) ([]*Task, error) {
+	return s.list(func(t *Task) bool { return t.Date == date }, after, limit), nil
+}
+
+func (s *MemoryStore) CountTasksByDate(_ context.Context, date string) (int, error) {
+	return s.count(func(t *Task) bool { return t.Date == date }), nil
+}
+
+func (s *MemoryStore) TasksInRange(_ context.Context, from, to string, after Cursor, limit int) ([]*Task, error) {
+	return s.list(inRange(from, to), after, limit), nil
+}
+
+func (s *MemoryStore) CountTasksInRange(_ context.Context, from, to string) (int, error) {
+	return s.count(inRange(from, to)), nil
+}
+
+func (s *MemoryStore) AgendaTasks(_ context.Context, from, to string) ([]*Task, error) {
+	return s.list(func(t *Task) bool {
+		return t.Date <= to && (t.Repeat != "" || t.Date >= from)
+	}, Cursor{}, -1), nil
+}
+
+func (s *MemoryStore) CountTasksByState(_ context.Context, today string) (*TaskCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var c TaskCounts
+	for _, t := range s.tasks {
+		switch {
+		case t.Repeat != "":
+			c.Repeating++
+		case t.Date < today:
+			c.Overdue++
+		case t.Date == today:
+			c.Today++
+		default:
+			c.Future++
+		}
+	}
+	return &c, nil
+}
+
+func inRange(from, to string) func(t *Task) bool {
+	return func(t *Task) bool {
+		return (from == "" || t.Date >= from) && (to == "" || t.Date <= to)
+	}
+}
+
+// sorted returns copies of the tasks matching the filter in (date, id)
+// order. The caller must hold the lock.
+func (s *MemoryStore) sorted(match func(t *Task) bool) []*Task {
+	tasks := make([]*Task, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		if match == nil || match(&t) {
+			tasks = append(tasks, &t)
+		}
+	}
+	slices.SortFunc(tasks, func(a, b *Task) int {
+		if c := strings.Compare(a.Date, b.Date); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return tasks
+}
+
+func (s *MemoryStore) list(match func(t *Task) bool, after Cursor, limit int) []*Task {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tasks := s.sorted(func(t *Task) bool {
+		if !after.IsZero() && !(t.Date > after.Date || t.Date == after.Date && t.ID > after.ID) {
+			return false
+		}
+		return match == nil || match(t)
+	})
+	if limit >= 0 && len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+	return tasks
+}
+
+func (s *MemoryStore) count(match func(t *Task) bool) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.sorted(match))
+}
@@ -0,0 +1,448 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// The in-memory search reproduces the scheduler_search index: the unicode61
+// tokenizer with "ё" and Latin diacritics folded, phrases and prefixes per
+// column, bm25 with the same column weights and the output of highlight()
+// and snippet() as called by SearchTasks.
+
+const (
+	titleColumn   = 0
+	commentColumn = 1
+
+	snippetTokens   = 12
+	snippetEllipsis = "…"
+)
+
+var searchWeights = [2]float64{2.0, 1.0}
+
+// latinFolds maps lowercase Latin letters with diacritics to their base
+// letter, as remove_diacritics 2 does.
+var latinFolds = func() map[rune]rune {
+	from := []rune("àáâãäåçèéêëìíîïñòóôõöùúûüýÿāăąćĉċčďēĕėęěĝğġģĥĩīĭįĵķĺļľńņňōŏőŕŗřśŝşšţťũūŭůűųŵŷźżžơưǎǐǒǔǖǘǚǜǟǡǧǩǫǭǰǵǹǻȁȃȅȇȉȋȍȏȑȓȕȗșțȟȧȩȫȭȯȱȳ" +
+		"ḁḃḅḇḉḋḍḏḑḓḕḗḙḛḝḟḡḣḥḧḩḫḭḯḱḳḵḷḹḻḽḿṁṃṅṇṉṋṍṏṑṓṕṗṙṛṝṟṡṣṥṧṩṫṭṯṱṳṵṷṹṻṽṿẁẃẅẇẉẋẍẏẑẓẕẖẗẘẙạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ")
+	to := []rune("aaaaaaceeeeiiiinooooouuuuyyaaaccccdeeeeegggghiiiijklllnnnooorrrssssttuuuuuuwyzzzouaiouuuuuaagkoojgnaaaeeiioorruusthaeooooy" +
+		"abbbcdddddeeeeefghhhhhiikkkllllmmmnnnnoooopprrrrsssssttttuuuuuvvwwwwwxxyzzzhtwyaaaaaaaaaaaaeeeeeeeeiioooooooooooouuuuuuuyyyy")
+	folds := make(map[rune]rune, len(from))
+	for i, r := range from {
+		folds[r] = to[i]
+	}
+	return folds
+}()
+
+type searchToken struct {
+	text       string
+	start, end int
+}
+
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Co, r) || unicode.Is(unicode.Mn, r)
+}
+
+// searchTokens splits s into folded tokens with their byte offsets in s.
+func searchTokens(s string) []searchToken {
+	var tokens []searchToken
+	var b strings.Builder
+	start := -1
+	for i, r := range s {
+		if !isTokenRune(r) {
+			if start >= 0 {
+				tokens = append(tokens, searchToken{text: b.String(), start: start, end: i})
+				b.Reset()
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		switch r {
+		case 'ё':
+			r = 'е'
+		default:
+			if f, ok := latinFolds[r]; ok {
+				r = f
+			}
+		}
+		b.WriteRune(r)
+	}
+	if start >= 0 {
+		tokens = append(tokens, searchToken{text: b.String(), start: start, end: len(s)})
+	}
+	return tokens
+}
+
+// searchPhrase is a text term of the query; column is -1 for both columns.
+type searchPhrase struct {
+	tokens []string
+	prefix bool
+	column int
+}
+
+func newSearchPhrase(t QueryTerm) searchPhrase {
+	p := searchPhrase{prefix: t.Prefix, column: -1}
+	switch t.Field {
+	case FieldTitle:
+		p.column = titleColumn
+	case FieldComment:
+		p.column = commentColumn
+	}
+	for _, tok := range searchTokens(t.Value) {
+		p.tokens = append(p.tokens, tok.text)
+	}
+	return p
+}
+
+// instances returns the token positions where the phrase starts in a column.
+func (p searchPhrase) instances(doc *searchDoc, column int) []int {
+	if len(p.tokens) == 0 || p.column >= 0 && p.column != column {
+		return nil
+	}
+	var found []int
+	tokens := doc.columns[column]
+	for i := 0; i+len(p.tokens) <= len(tokens); i++ {
+		match := true
+		for k, text := range p.tokens {
+			got := tokens[i+k].text
+			if p.prefix && k == len(p.tokens)-1 {
+				match = strings.HasPrefix(got, text)
+			} else {
+				match = got == text
+			}
+			if !match {
+				break
+			}
+		}
+		if match {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+func (p searchPhrase) matches(doc *searchDoc) bool {
+	return len(p.instances(doc, titleColumn)) > 0 || len(p.instances(doc, commentColumn)) > 0
+}
+
+type searchDoc struct {
+	task    *Task
+	text    [2]string
+	columns [2][]searchToken
+}
+
+func newSearchDoc(t *Task) *searchDoc {
+	doc := &searchDoc{task: t, text: [2]string{t.Title, t.Comment}}
+	for c, text := range doc.text {
+		doc.columns[c] = searchTokens(text)
+	}
+	return doc
+}
+
+func (doc *searchDoc) size() int {
+	return len(doc.columns[titleColumn]) + len(doc.columns[commentColumn])
+}
+
+// searchSpan is a run of tokens covered by one or more overlapping phrase
+// instances, last inclusive.
+type searchSpan struct {
+	first, last int
+}
+
+func (doc *searchDoc) spans(phrases []searchPhrase, column int) []searchSpan {
+	var spans []searchSpan
+	for _, p := range phrases {
+		for _, i := range p.instances(doc, column) {
+			spans = append(spans, searchSpan{i, i + len(p.tokens) - 1})
+		}
+	}
+	slices.SortFunc(spans, func(a, b searchSpan) int { return cmp.Compare(a.first, b.first) })
+
+	var merged []searchSpan
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.first <= merged[n-1].last {
+			merged[n-1].last = max(merged[n-1].last, s.last)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+func (doc *searchDoc) highlight(phrases []searchPhrase, column int) string {
+	text, tokens := doc.text[column], doc.columns[column]
+	var b strings.Builder
+	off := 0
+	for _, s := range doc.spans(phrases, column) {
+		start, end := tokens[s.first].start, tokens[s.last].end
+		b.WriteString(text[off:start])
+		b.WriteString(HighlightStart + text[start:end] + HighlightEnd)
+		off = end
+	}
+	b.WriteString(text[off:])
+	return b.String()
+}
+
+// snippet picks the fragment of snippetTokens tokens with the most distinct
+// phrases, preferring fragments that start a sentence, like snippet() does.
+func (doc *searchDoc) snippet(phrases []searchPhrase, column int) string {
+	text, tokens := doc.text[column], doc.columns[column]
+	size := len(tokens)
+
+	type instance struct{ phrase, pos, size int }
+	var insts []instance
+	for i, p := range phrases {
+		for _, pos := range p.instances(doc, column) {
+			insts = append(insts, instance{i, pos, len(p.tokens)})
+		}
+	}
+	slices.SortStableFunc(insts, func(a, b instance) int { return cmp.Compare(a.pos, b.pos) })
+
+	score := func(from int) (int, int) {
+		seen := make(map[int]bool)
+		score, first, last := 0, -1, 0
+		for _, in := range insts {
+			if in.pos < from || in.pos >= from+snippetTokens {
+				continue
+			}
+			if seen[in.phrase] {
+				score++
+			} else {
+				score += 1000
+			}
+			seen[in.phrase] = true
+			if first < 0 {
+				first = in.pos
+			}
+			last = in.pos + in.size
+		}
+		adj := first - (snippetTokens-(last-first))/2
+		adj = max(min(adj, size-snippetTokens), 0)
+		return score, adj
+	}
+
+	// A sentence starts at the first token and after ". " or ": ".
+	var sentences []int
+	for i, tok := range tokens {
+		if i == 0 {
+			sentences = append(sentences, 0)
+			continue
+		}
+		before := strings.TrimRight(text[:tok.start], " \t\n\r")
+		if len(before) < tok.start && (strings.HasSuffix(before, ".") || strings.HasSuffix(before, ":")) {
+			sentences = append(sentences, i)
+		}
+	}
+
+	best, bestStart := 0, 0
+	for _, in := range insts {
+		if s, adj := score(in.pos); s > best {
+			best, bestStart = s, adj
+		}
+		if len(sentences) > 0 && size > snippetTokens {
+			j := 0
+			for j < len(sentences)-1 && sentences[j+1] <= in.pos {
+				j++
+			}
+			if first := sentences[j]; first < in.pos {
+				s, _ := score(first)
+				if first == 0 {
+					s += 120
+				} else {
+					s += 100
+				}
+				if s > best {
+					best, bestStart = s, first
+				}
+			}
+		}
+	}
+
+	if size == 0 {
+		return text
+	}
+	rangeEnd := bestStart + snippetTokens - 1
+	var b strings.Builder
+	off := 0
+	if bestStart > 0 {
+		b.WriteString(snippetEllipsis)
+		off = tokens[bestStart].start
+	}
+	for _, s := range doc.spans(phrases, column) {
+		if s.first < bestStart || s.first > rangeEnd {
+			continue
+		}
+		last := min(s.last, rangeEnd)
+		start, end := tokens[s.first].start, tokens[last].end
+		b.WriteString(text[off:start])
+		b.WriteString(HighlightStart + text[start:end] + HighlightEnd)
+		off = end
+	}
+	if rangeEnd >= size-1 {
+		b.WriteString(text[off:])
+	} else {
+		b.WriteString(text[off:tokens[rangeEnd].end])
+		b.WriteString(snippetEllipsis)
+	}
+	return b.String()
+}
+
+// searchFilter mirrors Query.filter: everything except positive text terms.
+func searchFilter(q *Query) func(doc *searchDoc) bool {
+	return func(doc *searchDoc) bool {
+		t := doc.task
+		for _, term := range q.Terms {
+			var ok bool
+			switch term.Field {
+			case "", FieldTitle, FieldComment:
+				if !term.Negated {
+					continue
+				}
+				ok = newSearchPhrase(term).matches(doc)
+			case FieldRepeat:
+				switch term.Value {
+				case "yes":
+					ok = t.Repeat != ""
+				case "no":
+					ok = t.Repeat == ""
+				default:
+					ok = t.Repeat == term.Value ||
+						len(t.Repeat) >= 2 && strings.EqualFold(t.Repeat[:2], term.Value+" ")
+				}
+			case FieldBefore:
+				ok = t.Date < term.Value
+			case FieldAfter:
+				ok = t.Date > term.Value
+			case FieldOn:
+				ok = t.Date == term.Value
+			}
+			if ok == term.Negated {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func positivePhrases(q *Query) []searchPhrase {
+	var phrases []searchPhrase
+	for _, t := range q.Terms {
+		if !t.Negated && t.ftsExpr() != "" {
+			phrases = append(phrases, newSearchPhrase(t))
+		}
+	}
+	return phrases
+}
+
+// matchingDocs returns the documents of all tasks and the indexes of those
+// matching q. The caller must hold the lock.
+func (s *MemoryStore) matchingDocs(q *Query) ([]*searchDoc, []int) {
+	tasks := s.sorted(nil)
+	docs := make([]*searchDoc, len(tasks))
+	var matched []int
+	phrases := positivePhrases(q)
+	filter := searchFilter(q)
+	for i, t := range tasks {
+		docs[i] = newSearchDoc(t)
+		ok := filter(docs[i])
+		for _, p := range phrases {
+			ok = ok && p.matches(docs[i])
+		}
+		if ok {
+			matched = append(matched, i)
+		}
+	}
+	return docs, matched
+}
+
+func (s *MemoryStore) SearchTasks(_ context.Context, q *Query, after Cursor, limit int) ([]*SearchResult, error) {
+	if !q.Ranked() {
+		filter := searchFilter(q)
+		tasks := s.list(func(t *Task) bool { return filter(newSearchDoc(t)) }, after, limit)
+		results := make([]*SearchResult, len(tasks))
+		for i, t := range tasks {
+			results[i] = &SearchResult{Task: *t}
+		}
+		return results, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	docs, matched := s.matchingDocs(q)
+	phrases := positivePhrases(q)
+
+	// bm25 statistics are taken over the whole index.
+	total := 0
+	for _, doc := range docs {
+		total += doc.size()
+	}
+	rows := float64(len(docs))
+	avgSize := float64(total) / rows
+	idf := make([]float64, len(phrases))
+	for i, p := range phrases {
+		hits := 0
+		for _, doc := range docs {
+			if p.matches(doc) {
+				hits++
+			}
+		}
+		idf[i] = math.Log((rows - float64(hits) + 0.5) / (float64(hits) + 0.5))
+		if idf[i] <= 0 {
+			idf[i] = 1e-6
+		}
+	}
+
+	results := make([]*SearchResult, 0, len(matched))
+	for _, i := range matched {
+		doc := docs[i]
+		k1, b := 1.2, 0.75
+		score := 0.0
+		for j, p := range phrases {
+			freq := 0.0
+			for c := range doc.columns {
+				freq += searchWeights[c] * float64(len(p.instances(doc, c)))
+			}
+			score += idf[j] * ((freq * (k1 + 1)) / (freq + k1*(1-b+b*float64(doc.size())/avgSize)))
+		}
+		r := &SearchResult{
+			Task:      *doc.task,
+			Rank:      -score,
+			Highlight: doc.highlight(phrases, titleColumn),
+			Snippet:   doc.snippet(phrases, commentColumn),
+		}
+		if !after.IsZero() && !(r.Rank > after.Rank || r.Rank == after.Rank && r.ID > after.ID) {
+			continue
+		}
+		results = append(results, r)
+	}
+
+	slices.SortFunc(results, func(a, b *SearchResult) int {
+		if c := cmp.Compare(a.Rank, b.Rank); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) CountSearchTasks(_ context.Context, q *Query) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, matched := s.matchingDocs(q)
+	return len(matched), nil
+}
@@ -0,0 +1,20 @@
+package db
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "todo_db_query_duration_seconds",
+	Help:    "Duration of pkg/db calls that reach the database, including failed ones.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"func"})
+
+// observe starts timing the function name; call the result when it returns.
+func observe(name string) func() {
+	start := time.Now()
+	return func() { queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds()) }
+}
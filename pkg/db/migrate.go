@@ -0,0 +1,247 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are named NNNN_description.sql and applied in order of NNNN.
+// Applied migrations are never edited; schema changes go into a new file.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// The Postgres store has its own migrations with the same numbering rules.
+//
+//go:embed migrations/postgres/*.sql
+var postgresMigrationFiles embed.FS
+
+const migrationsSchema = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    applied_at TEXT NOT NULL DEFAULT ''
+);
+`
+
+// ErrSchemaOutdated means the database lacks migrations of this server, for
+// instance because it was swapped for an older file while the server ran.
+var ErrSchemaOutdated = errors.New("database schema is older than this server")
+
+// ErrSchemaTooNew means the database was migrated by a newer version of the
+// server, which this one cannot safely work with.
+var ErrSchemaTooNew = errors.New("database schema is newer than this server")
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns the embedded SQLite migrations in order.
+func Migrations() ([]Migration, error) {
+	return loadMigrations(migrationFiles, "migrations/*.sql")
+}
+
+// PostgresMigrations returns the embedded Postgres migrations in order.
+func PostgresMigrations() ([]Migration, error) {
+	return loadMigrations(postgresMigrationFiles, "migrations/postgres/*.sql")
+}
+
+func loadMigrations(fsys embed.FS, pattern string) ([]Migration, error) {
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".sql")
+		number, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration name %q", file)
+		}
+		data, err := fsys.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].Version)
+		}
+	}
+	return migrations, nil
+}
+
+// PendingMigrations returns the migrations that Init would apply to dbFile,
+// without changing the file or creating it.
+func PendingMigrations(dbFile string) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dbFile); errors.Is(err, os.ErrNotExist) {
+		return migrations, nil
+	}
+
+	conn, err := sql.Open("sqlite", dbFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+
+	var exists bool
+	err = conn.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	if !exists {
+		return migrations, nil
+	}
+
+	applied, err := appliedMigrations(context.Background(), conn)
+	if err != nil {
+		return nil, err
+	}
+	return pending(migrations, applied)
+}
+
+// migrate applies pending migrations, each in its own transaction together
+// with its schema_migrations row, so a failed migration leaves the database
+// at the previous version.
+func migrate(conn *sql.DB, d dialect, migrations []Migration) error {
+	if _, err := conn.Exec(migrationsSchema); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := appliedMigrations(context.Background(), conn)
+	if err != nil {
+		return err
+	}
+	todo, err := pending(migrations, applied)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range todo {
+		if err := applyMigration(conn, d, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(conn *sql.DB, d dialect, m Migration) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %s: %w", m.Name, err)
+	}
+	defer tx.Rollback()
+
+	if d.lockMigrations != "" {
+		if _, err := tx.Exec(d.lockMigrations); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+	}
+
+	// Another process may have applied it since the versions were read.
+	var done bool
+	err = tx.QueryRow(d.bind(`SELECT COUNT(*) > 0 FROM schema_migrations WHERE version = ?`), m.Version).Scan(&done)
+	if err != nil {
+		return fmt.Errorf("failed to check migration %s: %w", m.Name, err)
+	}
+	if done {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", m.Name, err)
+	}
+	_, err = tx.Exec(d.bind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+		m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.Name, err)
+	}
+	return nil
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.DB) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan schema version: %w", err)
+		}
+		applied[version] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over schema versions: %w", err)
+	}
+	return applied, nil
+}
+
+// pending returns the migrations missing from applied. A version this
+// binary does not know about means the database is newer than the binary.
+func pending(migrations []Migration, applied map[int]bool) ([]Migration, error) {
+	known := make(map[int]bool, len(migrations))
+	var todo []Migration
+	for _, m := range migrations {
+		known[m.Version] = true
+		if !applied[m.Version] {
+			todo = append(todo, m)
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("%w: it has migration %d, latest known is %d",
+				ErrSchemaTooNew, version, migrations[len(migrations)-1].Version)
+		}
+	}
+	return todo, nil
+}
+
+// checkSchema returns the version of the database if it has exactly the
+// migrations given.
+func checkSchema(ctx context.Context, conn *sql.DB, migrations []Migration) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	todo, err := pending(migrations, applied)
+	if err != nil {
+		return 0, err
+	}
+	if len(todo) > 0 {
+		return 0, fmt.Errorf("%w: migration %s is not applied", ErrSchemaOutdated, todo[0].Name)
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
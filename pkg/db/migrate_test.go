@@ -0,0 +1,83 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, m.Name)
+	}
+
+	// An empty file that already exists gets the full schema.
+	dbFile := filepath.Join(t.TempDir(), "scheduler.db")
+	require.NoError(t, os.WriteFile(dbFile, nil, 0o644))
+	pending, err := PendingMigrations(dbFile)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(migrations))
+
+	require.NoError(t, Init(dbFile))
+	_, err = AddTask(t.Context(), &Task{Date: "20261019", Title: "Миграции"})
+	require.NoError(t, err)
+	pending, err = PendingMigrations(dbFile)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// A second start applies nothing and keeps the data.
+	require.NoError(t, Init(dbFile))
+	tasks, err := Tasks(t.Context(), Cursor{}, -1)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+	version, err := SchemaVersion(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, migrations[len(migrations)-1].Version, version)
+
+	_, err = db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (9999, '9999_future')`)
+	require.NoError(t, err)
+	assert.ErrorIs(t, Init(dbFile), ErrSchemaTooNew)
+	_, err = PendingMigrations(dbFile)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+	_, err = SchemaVersion(t.Context())
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+
+	_, err = db.Exec(`DELETE FROM schema_migrations WHERE version IN (1, 9999)`)
+	require.NoError(t, err)
+	_, err = SchemaVersion(t.Context())
+	assert.ErrorIs(t, err, ErrSchemaOutdated)
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	// Databases created before versioned migrations have the tables but no
+	// schema_migrations; they are adopted without losing data.
+	dbFile := filepath.Join(t.TempDir(), "scheduler.db")
+	require.NoError(t, Init(dbFile))
+	_, err := AddTask(t.Context(), &Task{Date: "20261019", Title: "Старая задача"})
+	require.NoError(t, err)
+	_, err = db.Exec(`DROP TABLE schema_migrations`)
+	require.NoError(t, err)
+	// Columns added by later migrations did not exist in such databases.
+	for _, column := range []string{"retry_at", "failed_for", "created_at"} {
+		_, err = db.Exec(`ALTER TABLE reminders DROP COLUMN ` + column)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, Init(dbFile))
+	tasks, err := Tasks(t.Context(), Cursor{}, -1)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 1)
+
+	q, err := ParseQuery("старая", time.Now())
+	require.NoError(t, err)
+	found, err := SearchTasks(t.Context(), q, Cursor{}, -1)
+	if assert.NoError(t, err) {
+		assert.Len(t, found, 1)
+	}
+}
@@ -0,0 +1,11 @@
+-- Migrations 0001-0006 use IF NOT EXISTS because databases created before
+-- versioned migrations already have some of these tables.
+CREATE TABLE IF NOT EXISTS scheduler (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date CHAR(8) NOT NULL DEFAULT "" CHECK (LENGTH(date) = 8),
+    title VARCHAR(255) NOT NULL DEFAULT "" CHECK (LENGTH(title) <= 255),
+    comment TEXT NOT NULL DEFAULT "",
+    repeat VARCHAR(128) NOT NULL DEFAULT "" CHECK (LENGTH(repeat) <= 128)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduler_date ON scheduler (date);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS reminders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    offset_days INTEGER NOT NULL DEFAULT 0 CHECK (offset_days >= 0),
+    time CHAR(5) NOT NULL DEFAULT "09:00" CHECK (LENGTH(time) = 5),
+    sent_for CHAR(8) NOT NULL DEFAULT "",
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT ""
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_task ON reminders (task_id);
+
+CREATE TRIGGER IF NOT EXISTS scheduler_reminders_delete AFTER DELETE ON scheduler
+BEGIN
+    DELETE FROM reminders WHERE task_id = old.id;
+END;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS time_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    occurrence CHAR(8) NOT NULL CHECK (LENGTH(occurrence) = 8),
+    day CHAR(8) NOT NULL CHECK (LENGTH(day) = 8),
+    started_at INTEGER NOT NULL,
+    stopped_at INTEGER,
+    duration INTEGER NOT NULL DEFAULT 0 CHECK (duration >= 0),
+    comment TEXT NOT NULL DEFAULT ""
+);
+
+CREATE INDEX IF NOT EXISTS idx_time_entries_task ON time_entries (task_id, occurrence);
+CREATE INDEX IF NOT EXISTS idx_time_entries_day ON time_entries (day);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_running ON time_entries ((stopped_at IS NULL)) WHERE stopped_at IS NULL;
+
+CREATE TRIGGER IF NOT EXISTS scheduler_time_entries_delete AFTER DELETE ON scheduler
+BEGIN
+    UPDATE time_entries
+    SET stopped_at = CAST(strftime('%s', 'now') AS INTEGER),
+        duration = MAX(0, CAST(strftime('%s', 'now') AS INTEGER) - started_at)
+    WHERE task_id = old.id AND stopped_at IS NULL;
+END;
@@ -0,0 +1,42 @@
+-- The index stores title and comment with "ё" folded to "е"; case and Latin
+-- diacritics are folded by the unicode61 tokenizer itself. Folding is done with
+-- the built-in replace() so that the triggers work for any SQLite client, and
+-- highlight() still shows the original text read from the scheduler table.
+-- scheduler_fts is the previous index without "ё" folding.
+DROP TRIGGER IF EXISTS scheduler_fts_insert;
+DROP TRIGGER IF EXISTS scheduler_fts_delete;
+DROP TRIGGER IF EXISTS scheduler_fts_update;
+DROP TABLE IF EXISTS scheduler_fts;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_search USING fts5 (
+    title,
+    comment,
+    content = 'scheduler',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS scheduler_search_insert AFTER INSERT ON scheduler
+BEGIN
+    INSERT INTO scheduler_search (rowid, title, comment)
+    VALUES (new.id, replace(replace(new.title, 'ё', 'е'), 'Ё', 'Е'), replace(replace(new.comment, 'ё', 'е'), 'Ё', 'Е'));
+END;
+
+CREATE TRIGGER IF NOT EXISTS scheduler_search_delete AFTER DELETE ON scheduler
+BEGIN
+    INSERT INTO scheduler_search (scheduler_search, rowid, title, comment)
+    VALUES ('delete', old.id, replace(replace(old.title, 'ё', 'е'), 'Ё', 'Е'), replace(replace(old.comment, 'ё', 'е'), 'Ё', 'Е'));
+END;
+
+CREATE TRIGGER IF NOT EXISTS scheduler_search_update AFTER UPDATE OF title, comment ON scheduler
+BEGIN
+    INSERT INTO scheduler_search (scheduler_search, rowid, title, comment)
+    VALUES ('delete', old.id, replace(replace(old.title, 'ё', 'е'), 'Ё', 'Е'), replace(replace(old.comment, 'ё', 'е'), 'Ё', 'Е'));
+    INSERT INTO scheduler_search (rowid, title, comment)
+    VALUES (new.id, replace(replace(new.title, 'ё', 'е'), 'Ё', 'Е'), replace(replace(new.comment, 'ё', 'е'), 'Ё', 'Е'));
+END;
+
+-- Tasks created before the search index existed have to be indexed once.
+INSERT INTO scheduler_search (scheduler_search) VALUES ('delete-all');
+INSERT INTO scheduler_search (rowid, title, comment)
+SELECT id, replace(replace(title, 'ё', 'е'), 'Ё', 'Е'), replace(replace(comment, 'ё', 'е'), 'Ё', 'Е') FROM scheduler;
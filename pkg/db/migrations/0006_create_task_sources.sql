@@ -0,0 +1,13 @@
+-- task_sources remembers the calendar UID a task was imported from, so that
+-- importing the same file again updates the tasks instead of duplicating them.
+CREATE TABLE IF NOT EXISTS task_sources (
+    uid TEXT PRIMARY KEY,
+    task_id INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_sources_task_id ON task_sources (task_id);
+
+CREATE TRIGGER IF NOT EXISTS scheduler_task_sources_delete AFTER DELETE ON scheduler
+BEGIN
+    DELETE FROM task_sources WHERE task_id = old.id;
+END;
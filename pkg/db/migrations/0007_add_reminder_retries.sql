@@ -0,0 +1,4 @@
+-- retry_at postpones delivery after a failed attempt ("2006-01-02 15:04"),
+-- failed_for is the task date for which delivery was given up.
+ALTER TABLE reminders ADD COLUMN retry_at TEXT NOT NULL DEFAULT "";
+ALTER TABLE reminders ADD COLUMN failed_for CHAR(8) NOT NULL DEFAULT "";
@@ -0,0 +1,4 @@
+-- created_at is when the reminder was added ("2006-01-02 15:04"). A reminder
+-- whose time had already passed by then is not sent for that task date.
+-- Reminders added before this column existed keep "" and are never skipped.
+ALTER TABLE reminders ADD COLUMN created_at TEXT NOT NULL DEFAULT "";
@@ -0,0 +1,11 @@
+-- The checks mirror the SQLite scheduler table. Ids come from an identity
+-- column, so like AUTOINCREMENT they are never reused.
+CREATE TABLE scheduler (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    date TEXT NOT NULL DEFAULT '' CHECK (LENGTH(date) = 8),
+    title TEXT NOT NULL DEFAULT '' CHECK (LENGTH(title) <= 255),
+    comment TEXT NOT NULL DEFAULT '',
+    repeat TEXT NOT NULL DEFAULT '' CHECK (LENGTH(repeat) <= 128)
+);
+
+CREATE INDEX idx_scheduler_date ON scheduler (date);
@@ -0,0 +1,350 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresStore is the TaskStore over a shared Postgres database. Without a
+// full-text index text terms match substrings with ILIKE, and ranked results
+// are ordered by where the terms were found: a title match weighs twice as
+// much as a comment one, as in the SQLite index. Highlights and snippets mark
+// the words that start with a term.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+var _ TaskStore = (*PostgresStore)(nil)
+
+// OpenPostgres connects to the database at dsn and applies pending migrations.
+func OpenPostgres(dsn string) (*PostgresStore, error) {
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	migrations, err := PostgresMigrations()
+	if err == nil {
+		err = migrate(conn, postgresDialect, migrations)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: conn}, nil
+}
+
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	defer observe("Ping")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	return s.db.PingContext(ctx)
+}
+
+func (s *PostgresStore) SchemaVersion(ctx context.Context) (int, error) {
+	defer observe("SchemaVersion")()
+	migrations, err := PostgresMigrations()
+	if err != nil {
+		return 0, err
+	}
+	return checkSchema(ctx, s.db, migrations)
+}
+
+// PendingPostgresMigrations returns the migrations that OpenPostgres would
+// apply to the database at dsn, without changing it.
+func PendingPostgresMigrations(dsn string) ([]Migration, error) {
+	migrations, err := PostgresMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+
+	var exists bool
+	err = conn.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	if !exists {
+		return migrations, nil
+	}
+
+	applied, err := appliedMigrations(context.Background(), conn)
+	if err != nil {
+		return nil, err
+	}
+	return pending(migrations, applied)
+}
+
+func (s *PostgresStore) AddTask(ctx context.Context, task *Task) (int64, error) {
+	defer observe("AddTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var id int64
+	query := `INSERT INTO scheduler (date, title, comment, repeat) VALUES ($1, $2, $3, $4) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, task.Date, task.Title, task.Comment, task.Repeat).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add task: %w", err)
+	}
+	return id, nil
+}
+
+// GetTask parses the id the way SQLite compares it with the id column, as
+// Postgres rejects a text id where a bigint is expected.
+func (s *PostgresStore) GetTask(ctx context.Context, id string) (*Task, error) {
+	defer observe("GetTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	n, ok := parseID(id)
+	if !ok {
+		return nil, fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+
+	var task Task
+	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE id = $1`
+	err := s.db.QueryRowContext(ctx, query, n).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return &task, nil
+}
+
+func (s *PostgresStore) UpdateTask(ctx context.Context, task *Task) error {
+	defer observe("UpdateTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE scheduler SET date = $1, title = $2, comment = $3, repeat = $4 WHERE id = $5`
+	res, err := s.db.ExecContext(ctx, query, task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected after update: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("incorrect id for updating task: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteTask(ctx context.Context, id string) error {
+	defer observe("DeleteTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	n, ok := parseID(id)
+	if !ok {
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM scheduler WHERE id = $1`, n)
+	if err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected after delete: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *PostgresStore) UpdateDate(ctx context.Context, next string, id string) error {
+	defer observe("UpdateDate")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	n, ok := parseID(id)
+	if !ok {
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE scheduler SET date = $1 WHERE id = $2`, next, n)
+	if err != nil {
+		return fmt.Errorf("failed to update task date: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected after date update: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Tasks(ctx context.Context, after Cursor, limit int) ([]*Task, error) {
+	defer observe("Tasks")()
+	return queryTasks(ctx, s.db, postgresDialect, "", nil, after, limit)
+}
+
+func (s *PostgresStore) CountTasks(ctx context.Context) (int, error) {
+	defer observe("CountTasks")()
+	return countRows(ctx, s.db, postgresDialect, "", nil)
+}
+
+func (s *PostgresStore) GetTasksByDate(ctx context.Context, date string, after Cursor, limit int) ([]*Task, error) {
+	defer observe("GetTasksByDate")()
+	return queryTasks(ctx, s.db, postgresDialect, "date = ?", []any{date}, after, limit)
+}
+
+func (s *PostgresStore) CountTasksByDate(ctx context.Context, date string) (int, error) {
+	defer observe("CountTasksByDate")()
+	return countRows(ctx, s.db, postgresDialect, "date = ?", []any{date})
+}
+
+func (s *PostgresStore) TasksInRange(ctx context.Context, from, to string, after Cursor, limit int) ([]*Task, error) {
+	defer observe("TasksInRange")()
+	where, args := dateRange(from, to)
+	return queryTasks(ctx, s.db, postgresDialect, where, args, after, limit)
+}
+
+func (s *PostgresStore) CountTasksInRange(ctx context.Context, from, to string) (int, error) {
+	defer observe("CountTasksInRange")()
+	where, args := dateRange(from, to)
+	return countRows(ctx, s.db, postgresDialect, where, args)
+}
+
+func (s *PostgresStore) AgendaTasks(ctx context.Context, from, to string) ([]*Task, error) {
+	defer observe("AgendaTasks")()
+	return queryTasks(ctx, s.db, postgresDialect, agendaWhere, []any{to, from}, Cursor{}, -1)
+}
+
+func (s *PostgresStore) CountTasksByState(ctx context.Context, today string) (*TaskCounts, error) {
+	defer observe("CountTasksByState")()
+	return countByState(ctx, s.db, postgresDialect, today)
+}
+
+func (s *PostgresStore) SearchTasks(ctx context.Context, q *Query, after Cursor, limit int) ([]*SearchResult, error) {
+	defer observe("SearchTasks")()
+	where, whereArgs := postgresSearchWhere(q)
+
+	if !q.Ranked() {
+		tasks, err := queryTasks(ctx, s.db, postgresDialect, where, whereArgs, after, limit)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]*SearchResult, len(tasks))
+		for i, t := range tasks {
+			results[i] = &SearchResult{Task: *t}
+		}
+		return results, nil
+	}
+
+	score, args := postgresScore(q)
+	query := `SELECT id, date, title, comment, repeat, score FROM (
+    SELECT id, date, title, comment, repeat, ` + score + ` AS score
+    FROM scheduler WHERE ` + where + `
+) AS found`
+	args = append(args, whereArgs...)
+	if !after.IsZero() {
+		query += ` WHERE score > ? OR (score = ? AND id > ?)`
+		args = append(args, after.Rank, after.Rank, after.ID)
+	}
+	query += ` ORDER BY score, id`
+	if limit >= 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, postgresDialect.bind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %w", err)
+	}
+	defer rows.Close()
+
+	phrases := positivePhrases(q)
+	for i := range phrases {
+		phrases[i].prefix = true
+	}
+
+	results := make([]*SearchResult, 0)
+	for rows.Next() {
+		var r SearchResult
+		err := rows.Scan(&r.ID, &r.Date, &r.Title, &r.Comment, &r.Repeat, &r.Rank)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search task row: %w", err)
+		}
+		doc := newSearchDoc(&r.Task)
+		r.Highlight = doc.highlight(phrases, titleColumn)
+		r.Snippet = doc.snippet(phrases, commentColumn)
+		results = append(results, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over search task rows: %w", err)
+	}
+
+	return results, nil
+}
+
+func (s *PostgresStore) CountSearchTasks(ctx context.Context, q *Query) (int, error) {
+	defer observe("CountSearchTasks")()
+	where, args := postgresSearchWhere(q)
+	return countRows(ctx, s.db, postgresDialect, where, args)
+}
+
+// postgresSearchWhere is the query filter together with the positive text
+// terms, which SQLite matches against its index instead.
+func postgresSearchWhere(q *Query) (string, []any) {
+	var conds []string
+	var args []any
+	for _, t := range q.Terms {
+		if t.Negated || t.ftsExpr() == "" {
+			continue
+		}
+		cond, condArgs := ilikeCond(t)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+	if where, whereArgs := q.filter(postgresDialect); where != "" {
+		conds = append(conds, where)
+		args = append(args, whereArgs...)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// postgresScore sums the column weights of the positive text terms found in
+// a task, negated so that like bm25 lower is better.
+func postgresScore(q *Query) (string, []any) {
+	var parts []string
+	var args []any
+	for _, t := range q.Terms {
+		if t.Negated || t.ftsExpr() == "" {
+			continue
+		}
+		pattern := containsPattern(t.Value)
+		if t.Field != FieldComment {
+			parts = append(parts, "CASE WHEN "+foldedTitle+" ILIKE ? THEN 2 ELSE 0 END")
+			args = append(args, pattern)
+		}
+		if t.Field != FieldTitle {
+			parts = append(parts, "CASE WHEN "+foldedComment+" ILIKE ? THEN 1 ELSE 0 END")
+			args = append(args, pattern)
+		}
+	}
+	return "-CAST(" + strings.Join(parts, " + ") + " AS DOUBLE PRECISION)", args
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindDollar(t *testing.T) {
+	assert.Equal(t, "SELECT id FROM scheduler WHERE date = $1 AND repeat <> '?' AND id > $2",
+		bindDollar("SELECT id FROM scheduler WHERE date = ? AND repeat <> '?' AND id > ?"))
+	assert.Equal(t, "SELECT 1", bindDollar("SELECT 1"))
+}
+
+// TestPostgresStore runs against TODO_TEST_POSTGRES_DSN, or a throwaway
+// server started from the postgres binaries if they are installed. Every
+// store gets its own schema.
+func TestPostgresStore(t *testing.T) {
+	dsn := postgresDSN(t)
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	schemas := 0
+	newStore := func(t *testing.T) TaskStore {
+		schemas++
+		schema := fmt.Sprintf("store_test_%d_%d", os.Getpid(), schemas)
+		_, err := admin.Exec(`CREATE SCHEMA ` + schema)
+		require.NoError(t, err)
+		t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+		s, err := OpenPostgres(dsn + " search_path=" + schema)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+
+		pending, err := PendingPostgresMigrations(dsn + " search_path=" + schema)
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+		return s
+	}
+
+	testTaskStore(t, newStore)
+
+	t.Run("Search", func(t *testing.T) {
+		s := newStore(t)
+		ctx := t.Context()
+		var all []int64
+		for _, task := range []Task{
+			{Date: "20261019", Title: "Квартальный отчёт", Comment: "собрать цифры", Repeat: "m 1"},
+			{Date: "20261020", Title: "Созвон", Comment: "обсудить отчет и план"},
+			{Date: "20261021", Title: "Deploy", Comment: "выкатить релиз, 100% готов"},
+			{Date: "20261022", Title: "Отчетность", Comment: "черновик"},
+			{Date: "20261023", Title: "Обед", Comment: "Потом отправить ОТЧЁТ коллегам."},
+		} {
+			id, err := s.AddTask(ctx, &task)
+			require.NoError(t, err)
+			all = append(all, id)
+		}
+
+		search := func(query string, after Cursor, limit int) []*SearchResult {
+			q, err := ParseQuery(query, time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, err)
+			results, err := s.SearchTasks(ctx, q, after, limit)
+			require.NoError(t, err)
+			count, err := s.CountSearchTasks(ctx, q)
+			require.NoError(t, err)
+			if after.IsZero() && limit < 0 {
+				assert.Len(t, results, count, query)
+			}
+			return results
+		}
+		resultIDs := func(results []*SearchResult) []int64 {
+			list := make([]int64, len(results))
+			for i, r := range results {
+				list[i] = r.ID
+			}
+			return list
+		}
+
+		// Terms match substrings; title matches come first.
+		results := search("отчет", Cursor{}, -1)
+		assert.Equal(t, []int64{all[0], all[3], all[1], all[4]}, resultIDs(results))
+		assert.Equal(t, "Квартальный "+HighlightStart+"отчёт"+HighlightEnd, results[0].Highlight)
+		assert.Equal(t, HighlightStart+"Отчетность"+HighlightEnd, results[1].Highlight)
+		assert.Equal(t, "Потом отправить "+HighlightStart+"ОТЧЁТ"+HighlightEnd+" коллегам.", results[3].Snippet)
+
+		var paged []int64
+		after := Cursor{}
+		for {
+			page := search("отчет", after, 3)
+			paged = append(paged, resultIDs(page)...)
+			if len(page) < 3 {
+				break
+			}
+			last := page[len(page)-1]
+			after = Cursor{Rank: last.Rank, ID: last.ID}
+		}
+		assert.Equal(t, resultIDs(results), paged)
+
+		assert.Equal(t, []int64{all[0], all[3]}, resultIDs(search("title:отчёт", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[1]}, resultIDs(search(`"отчет и план"`, Cursor{}, -1)))
+		assert.Equal(t, []int64{all[2]}, resultIDs(search("DEPLOY 100%", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[0], all[3], all[4]}, resultIDs(search("отчет -план", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[2]}, resultIDs(search("-отчет after:20261020", Cursor{}, -1)))
+		assert.Empty(t, search("_", Cursor{}, -1))
+	})
+}
+
+func postgresDSN(t *testing.T) string {
+	if dsn := os.Getenv("TODO_TEST_POSTGRES_DSN"); dsn != "" {
+		if strings.Contains(dsn, "://") {
+			var err error
+			dsn, err = pq.ParseURL(dsn)
+			require.NoError(t, err)
+		}
+		return dsn
+	}
+
+	initdb, err := exec.LookPath("initdb")
+	if err != nil {
+		found, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+		if len(found) == 0 {
+			t.Skip("postgres is not installed and TODO_TEST_POSTGRES_DSN is not set")
+		}
+		initdb = found[len(found)-1]
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("postgres can not run as root, set TODO_TEST_POSTGRES_DSN")
+	}
+
+	// The socket path must be short, so the server does not live in t.TempDir.
+	dir, err := os.MkdirTemp("", "pg")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--locale=C.UTF-8").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	server := exec.Command(filepath.Join(filepath.Dir(initdb), "postgres"),
+		"-D", data, "-k", dir, "-c", "listen_addresses=", "-p", "5432")
+	require.NoError(t, server.Start())
+	t.Cleanup(func() {
+		server.Process.Signal(os.Interrupt)
+		server.Wait()
+	})
+
+	dsn := "host=" + dir + " user=postgres dbname=postgres sslmode=disable"
+	conn, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return conn.Ping() == nil }, 10*time.Second, 100*time.Millisecond)
+	return dsn
+}
@@ -0,0 +1,282 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a parsed search box query: a conjunction of terms such as
+//
+//	title:deploy repeat:yes before:20261201 after:today -comment:draft "exact phrase"
+//
+// Bare words and "quoted phrases" are searched in title and comment, "word*"
+// matches by prefix and a leading "-" negates a term.
+type Query struct {
+	Terms []QueryTerm
+}
+
+// QueryTerm is a single term of a Query. Field is empty for full-text terms
+// over title and comment. For date fields Value is already resolved to the
+// 20060102 format. Pos is the 1-based character position of the term.
+type QueryTerm struct {
+	Negated bool
+	Field   string
+	Value   string
+	Prefix  bool
+	Pos     int
+}
+
+const (
+	FieldTitle   = "title"
+	FieldComment = "comment"
+	FieldRepeat  = "repeat"
+	FieldBefore  = "before"
+	FieldAfter   = "after"
+	FieldOn      = "on"
+)
+
+var queryFields = map[string]bool{
+	FieldTitle:   true,
+	FieldComment: true,
+	FieldRepeat:  true,
+	FieldBefore:  true,
+	FieldAfter:   true,
+	FieldOn:      true,
+}
+
+// SyntaxError points at the part of the query that could not be parsed.
+type SyntaxError struct {
+	Pos   int
+	Token string
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d: %q", e.Msg, e.Pos, e.Token)
+}
+
+type queryParser struct {
+	src   []rune
+	pos   int
+	today time.Time
+}
+
+// ParseQuery parses the search query; relative dates such as "today" are
+// resolved against today.
+func ParseQuery(input string, today time.Time) (*Query, error) {
+	p := &queryParser{src: []rune(input), today: today}
+	q := &Query{}
+
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.src) {
+			return q, nil
+		}
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+}
+
+func (p *queryParser) term() (QueryTerm, error) {
+	start := p.pos
+	term := QueryTerm{Pos: start + 1}
+
+	if p.src[p.pos] == '-' {
+		term.Negated = true
+		p.pos++
+		if p.pos >= len(p.src) || unicode.IsSpace(p.src[p.pos]) {
+			return term, p.errorAt(start, p.pos, "expected a term after '-'")
+		}
+	}
+
+	// A field is a latin identifier followed by a colon. Words like "16:00"
+	// or "Встреча:" that merely contain a colon are searched as text.
+	end := p.pos
+	for end < len(p.src) && (p.src[end] >= 'a' && p.src[end] <= 'z' || p.src[end] >= 'A' && p.src[end] <= 'Z') {
+		end++
+	}
+	if end > p.pos && end < len(p.src) && p.src[end] == ':' {
+		name := strings.ToLower(string(p.src[p.pos:end]))
+		if !queryFields[name] {
+			return term, p.errorAt(p.pos, end+1, "unknown field")
+		}
+		term.Field = name
+		p.pos = end + 1
+	}
+
+	valueStart := p.pos
+	value, quoted, err := p.value()
+	if err != nil {
+		return term, err
+	}
+	if !quoted && strings.HasSuffix(value, "*") {
+		value = strings.TrimRight(value, "*")
+		term.Prefix = true
+	}
+	if strings.TrimSpace(value) == "" {
+		if term.Field != "" && !quoted {
+			return term, p.errorAt(start, p.pos, "missing value for field")
+		}
+		return term, p.errorAt(start, p.pos, "empty search term")
+	}
+
+	switch term.Field {
+	case "", FieldTitle, FieldComment:
+		term.Value = searchFolder.Replace(value)
+	case FieldRepeat:
+		term.Value = strings.ToLower(value)
+		switch term.Value {
+		case "yes", "no", "y", "d", "w", "m":
+		default:
+			return term, p.errorAt(valueStart, p.pos, "repeat must be yes, no, y, d, w or m")
+		}
+	case FieldBefore, FieldAfter, FieldOn:
+		date, ok := p.date(value)
+		if !ok || term.Prefix {
+			return term, p.errorAt(valueStart, p.pos, "invalid date, use 20060102, 02.01.2006, today, tomorrow or yesterday")
+		}
+		term.Value = date
+	}
+
+	return term, nil
+}
+
+// value reads a quoted phrase or a word up to the next space.
+func (p *queryParser) value() (string, bool, error) {
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			return "", true, p.errorAt(start, p.pos, "unterminated quote")
+		}
+		p.pos++
+		return string(p.src[start+1 : p.pos-1]), true, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	return string(p.src[start:p.pos]), false, nil
+}
+
+func (p *queryParser) date(value string) (string, bool) {
+	switch strings.ToLower(value) {
+	case "today":
+		return p.today.Format("20060102"), true
+	case "tomorrow":
+		return p.today.AddDate(0, 0, 1).Format("20060102"), true
+	case "yesterday":
+		return p.today.AddDate(0, 0, -1).Format("20060102"), true
+	}
+	for _, layout := range []string{"20060102", "02.01.2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("20060102"), true
+		}
+	}
+	return "", false
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *queryParser) errorAt(start, end int, msg string) *SyntaxError {
+	return &SyntaxError{Pos: start + 1, Token: string(p.src[start:end]), Msg: msg}
+}
+
+// Ranked reports whether the query has positive full-text terms, in which
+// case results are ordered by relevance instead of date.
+func (q *Query) Ranked() bool {
+	return q.match() != ""
+}
+
+// match builds the FTS5 expression from the positive text terms. Every value
+// is quoted, so operators typed by the user can not change the expression.
+func (q *Query) match() string {
+	var parts []string
+	for _, t := range q.Terms {
+		if t.Negated {
+			continue
+		}
+		if expr := t.ftsExpr(); expr != "" {
+			parts = append(parts, expr)
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+func (t QueryTerm) ftsExpr() string {
+	var expr string
+	switch t.Field {
+	case "":
+		expr = quoteFTS(t.Value)
+	case FieldTitle, FieldComment:
+		expr = t.Field + " : " + quoteFTS(t.Value)
+	default:
+		return ""
+	}
+	if t.Prefix {
+		expr += "*"
+	}
+	return expr
+}
+
+// filter compiles everything except the positive text terms into a
+// parameterized condition on the scheduler table.
+func (q *Query) filter(d dialect) (string, []any) {
+	var conds []string
+	var args []any
+
+	for _, t := range q.Terms {
+		var cond string
+		switch t.Field {
+		case "", FieldTitle, FieldComment:
+			if !t.Negated {
+				continue
+			}
+			var condArgs []any
+			cond, condArgs = d.textCond(t)
+			args = append(args, condArgs...)
+		case FieldRepeat:
+			switch t.Value {
+			case "yes":
+				cond = "repeat <> ''"
+			case "no":
+				cond = "repeat = ''"
+			default:
+				cond = "(repeat = ? OR repeat " + d.like + " ?)"
+				args = append(args, t.Value, t.Value+" %")
+			}
+		case FieldBefore:
+			cond = "date < ?"
+			args = append(args, t.Value)
+		case FieldAfter:
+			cond = "date > ?"
+			args = append(args, t.Value)
+		case FieldOn:
+			cond = "date = ?"
+			args = append(args, t.Value)
+		}
+		if t.Negated {
+			cond = "NOT (" + cond + ")"
+		}
+		conds = append(conds, cond)
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+func quoteFTS(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
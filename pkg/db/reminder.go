@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// Reminder fires OffsetDays days before the task date at Time (HH:MM, local time).
+// SentFor holds the task date the reminder was last delivered for, so a reminder
+// of a repeating task re-arms itself once the task is moved to its next date.
+// FailedFor works the same way for a reminder whose delivery was given up.
+// CreatedAt ("2006-01-02 15:04") tells a reminder that was already in the
+// past when it was added from one that came due while the server was down.
+type Reminder struct {
+	ID         int64  `db:"id" json:"id"`
+	TaskID     int64  `db:"task_id" json:"task_id"`
+	OffsetDays int    `db:"offset_days" json:"offset_days"`
+	Time       string `db:"time" json:"time"`
+	SentFor    string `db:"sent_for" json:"sent_for"`
+	Attempts   int    `db:"attempts" json:"attempts"`
+	LastError  string `db:"last_error" json:"last_error"`
+	RetryAt    string `db:"retry_at" json:"retry_at"`
+	FailedFor  string `db:"failed_for" json:"failed_for"`
+	CreatedAt  string `db:"created_at" json:"created_at"`
+}
+
+// DueReminder is a reminder joined with the task it belongs to.
+type DueReminder struct {
+	Reminder
+	Task Task
+}
+
+func AddReminder(ctx context.Context, r *Reminder) (int64, error) {
+	defer observe("AddReminder")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO reminders (task_id, offset_days, time, created_at) VALUES (?, ?, ?, ?)`
+	res, err := db.ExecContext(ctx, query, r.TaskID, r.OffsetDays, r.Time, r.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add reminder: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+func GetReminders(ctx context.Context, taskID string) ([]*Reminder, error) {
+	defer observe("GetReminders")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, task_id, offset_days, time, sent_for, attempts, last_error, retry_at, failed_for, created_at FROM reminders WHERE task_id = ? ORDER BY offset_days DESC, time`
+	rows, err := db.QueryContext(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := make([]*Reminder, 0)
+	for rows.Next() {
+		var r Reminder
+		err := rows.Scan(&r.ID, &r.TaskID, &r.OffsetDays, &r.Time, &r.SentFor, &r.Attempts, &r.LastError, &r.RetryAt, &r.FailedFor, &r.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reminder row: %w", err)
+		}
+		reminders = append(reminders, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over reminder rows: %w", err)
+	}
+
+	return reminders, nil
+}
+
+func DeleteReminder(ctx context.Context, id string) error {
+	defer observe("DeleteReminder")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM reminders WHERE id = ?`
+	res, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete reminder: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected after reminder delete: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("reminder with id %s not found", id)
+	}
+	return nil
+}
+
+// DueReminders returns reminders whose fire time is not later than now
+// ("2006-01-02 15:04"), which were neither delivered nor given up for the
+// current task date and whose retry time, if any, has come.
+func DueReminders(ctx context.Context, now string) ([]*DueReminder, error) {
+	defer observe("DueReminders")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT r.id, r.task_id, r.offset_days, r.time, r.sent_for, r.attempts, r.last_error, r.retry_at, r.failed_for, r.created_at,
+       s.id, s.date, s.title, s.comment, s.repeat
+FROM reminders r JOIN scheduler s ON s.id = r.task_id
+WHERE r.sent_for <> s.date AND r.failed_for <> s.date AND r.retry_at <= ?1
+  AND date(substr(s.date, 1, 4) || '-' || substr(s.date, 5, 2) || '-' || substr(s.date, 7, 2),
+           '-' || r.offset_days || ' days') || ' ' || r.time <= ?1
+ORDER BY s.date, r.time`
+	rows, err := db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due reminders: %w", err)
+	}
+	defer rows.Close()
+
+	reminders := make([]*DueReminder, 0)
+	for rows.Next() {
+		var r DueReminder
+		err := rows.Scan(&r.ID, &r.TaskID, &r.OffsetDays, &r.Time, &r.SentFor, &r.Attempts, &r.LastError, &r.RetryAt, &r.FailedFor, &r.CreatedAt,
+			&r.Task.ID, &r.Task.Date, &r.Task.Title, &r.Task.Comment, &r.Task.Repeat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan due reminder row: %w", err)
+		}
+		reminders = append(reminders, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over due reminder rows: %w", err)
+	}
+
+	return reminders, nil
+}
+
+// MarkReminderSent records that the reminder was delivered for the task date.
+func MarkReminderSent(ctx context.Context, id int64, date string) error {
+	defer observe("MarkReminderSent")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE reminders SET sent_for = ?, attempts = 0, last_error = '', retry_at = '' WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, date, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark reminder as sent: %w", err)
+	}
+	return nil
+}
+
+// MarkReminderFailed stores the delivery error and postpones the next attempt
+// until retryAt ("2006-01-02 15:04").
+func MarkReminderFailed(ctx context.Context, id int64, reason, retryAt string) error {
+	defer observe("MarkReminderFailed")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE reminders SET attempts = attempts + 1, last_error = ?, retry_at = ? WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, reason, retryAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark reminder as failed: %w", err)
+	}
+	return nil
+}
+
+// GiveUpReminder stops delivery of the reminder for the task date after too
+// many failed attempts or because it was due before it was added. Attempts
+// start over for the next date of the task.
+func GiveUpReminder(ctx context.Context, id int64, date, reason string) error {
+	defer observe("GiveUpReminder")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `UPDATE reminders SET failed_for = ?, attempts = 0, last_error = ?, retry_at = '' WHERE id = ?`
+	_, err := db.ExecContext(ctx, query, date, reason, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark reminder as given up: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+var searchFolder = strings.NewReplacer("ё", "е", "Ё", "Е")
+
+// Highlighted fragments are wrapped in these private use characters, so the
+// API layer can escape the text before turning them into markup.
+const (
+	HighlightStart = "\uE000"
+	HighlightEnd   = "\uE001"
+)
+
+// SearchResult is a task found by full-text search. Rank is the bm25 score
+// (lower is better), Highlight is the title and Snippet a fragment of the
+// comment, both with matches wrapped in HighlightStart and HighlightEnd.
+type SearchResult struct {
+	Task
+	Rank      float64
+	Highlight string
+	Snippet   string
+}
+
+// SearchTasks runs a parsed query. Ranked queries are ordered by relevance
+// with title matches weighing more than comment ones, the rest by date.
+func SearchTasks(ctx context.Context, q *Query, after Cursor, limit int) ([]*SearchResult, error) {
+	defer observe("SearchTasks")()
+	where, whereArgs := q.filter(sqliteDialect)
+
+	if !q.Ranked() {
+		tasks, err := listTasks(ctx, where, whereArgs, after, limit)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]*SearchResult, len(tasks))
+		for i, t := range tasks {
+			results[i] = &SearchResult{Task: *t}
+		}
+		return results, nil
+	}
+
+	query := `SELECT id, date, title, comment, repeat, score, highlight, snippet FROM (
+    SELECT s.id, s.date, s.title, s.comment, s.repeat,
+           bm25(scheduler_search, 2.0, 1.0) AS score,
+           highlight(scheduler_search, 0, ?, ?) AS highlight,
+           snippet(scheduler_search, 1, ?, ?, '…', 12) AS snippet
+    FROM scheduler_search JOIN scheduler s ON s.id = scheduler_search.rowid
+    WHERE scheduler_search MATCH ?`
+	args := []any{HighlightStart, HighlightEnd, HighlightStart, HighlightEnd, q.match()}
+	if where != "" {
+		query += ` AND ` + where
+		args = append(args, whereArgs...)
+	}
+	query += `
+)`
+	if !after.IsZero() {
+		query += ` WHERE score > ? OR (score = ? AND id > ?)`
+		args = append(args, after.Rank, after.Rank, after.ID)
+	}
+	query += ` ORDER BY score, id LIMIT ?`
+	args = append(args, limit)
+
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*SearchResult, 0)
+	for rows.Next() {
+		var r SearchResult
+		err := rows.Scan(&r.ID, &r.Date, &r.Title, &r.Comment, &r.Repeat, &r.Rank, &r.Highlight, &r.Snippet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search task row: %w", err)
+		}
+		results = append(results, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over search task rows: %w", err)
+	}
+
+	return results, nil
+}
+
+func CountSearchTasks(ctx context.Context, q *Query) (int, error) {
+	defer observe("CountSearchTasks")()
+	where, args := q.filter(sqliteDialect)
+	if match := q.match(); match != "" {
+		cond := "id IN (SELECT rowid FROM scheduler_search WHERE scheduler_search MATCH ?)"
+		if where != "" {
+			where = cond + " AND " + where
+		} else {
+			where = cond
+		}
+		args = append([]any{match}, args...)
+	}
+	return countTasks(ctx, where, args)
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// GetSetting returns the stored value or an empty string if it is not set.
+func GetSetting(ctx context.Context, name string) (string, error) {
+	defer observe("GetSetting")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var value string
+	err := db.QueryRowContext(ctx, `SELECT value FROM settings WHERE name = ?`, name).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get setting %s: %w", name, err)
+	}
+	return value, nil
+}
+
+func SetSetting(ctx context.Context, name, value string) error {
+	defer observe("SetSetting")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `INSERT INTO settings (name, value) VALUES (?, ?)
+ON CONFLICT (name) DO UPDATE SET value = excluded.value`, name, value)
+	if err != nil {
+		return fmt.Errorf("failed to set setting %s: %w", name, err)
+	}
+	return nil
+}
+
+// InitSetting stores value unless the setting already exists and returns the
+// value in effect, so concurrent callers agree on a single value.
+func InitSetting(ctx context.Context, name, value string) (string, error) {
+	defer observe("InitSetting")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO settings (name, value) VALUES (?, ?)`, name, value)
+	if err != nil {
+		return "", fmt.Errorf("failed to init setting %s: %w", name, err)
+	}
+	return GetSetting(ctx, name)
+}
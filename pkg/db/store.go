@@ -0,0 +1,103 @@
+package db
+
+import "context"
+
+// TaskStore is the task storage used by the API. Ids are strings where the
+// API passes them through from requests; ids that are not a number simply do
+// not exist. Operations on missing tasks return an error wrapping ErrNotFound.
+// Every call is bound to ctx, normally the context of the request.
+//
+// Reminders, time tracking, imports and backups work with the SQLite database
+// directly and are not part of the store.
+type TaskStore interface {
+	AddTask(ctx context.Context, task *Task) (int64, error)
+	GetTask(ctx context.Context, id string) (*Task, error)
+	UpdateTask(ctx context.Context, task *Task) error
+	DeleteTask(ctx context.Context, id string) error
+	UpdateDate(ctx context.Context, next string, id string) error
+
+	// Listings are ordered by (date, id) and start right after the cursor;
+	// a negative limit means no limit.
+	Tasks(ctx context.Context, after Cursor, limit int) ([]*Task, error)
+	CountTasks(ctx context.Context) (int, error)
+	GetTasksByDate(ctx context.Context, date string, after Cursor, limit int) ([]*Task, error)
+	CountTasksByDate(ctx context.Context, date string) (int, error)
+	TasksInRange(ctx context.Context, from, to string, after Cursor, limit int) ([]*Task, error)
+	CountTasksInRange(ctx context.Context, from, to string) (int, error)
+	AgendaTasks(ctx context.Context, from, to string) ([]*Task, error)
+	CountTasksByState(ctx context.Context, today string) (*TaskCounts, error)
+
+	SearchTasks(ctx context.Context, q *Query, after Cursor, limit int) ([]*SearchResult, error)
+	CountSearchTasks(ctx context.Context, q *Query) (int, error)
+}
+
+// HealthChecker is implemented by the stores kept in a database, for
+// readiness checks.
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
+var (
+	_ HealthChecker = SQLiteStore{}
+	_ HealthChecker = (*PostgresStore)(nil)
+)
+
+// SQLiteStore is the TaskStore over the database opened by Init.
+type SQLiteStore struct{}
+
+var _ TaskStore = SQLiteStore{}
+
+func (SQLiteStore) AddTask(ctx context.Context, task *Task) (int64, error) {
+	return AddTask(ctx, task)
+}
+
+func (SQLiteStore) GetTask(ctx context.Context, id string) (*Task, error) { return GetTask(ctx, id) }
+func (SQLiteStore) UpdateTask(ctx context.Context, task *Task) error      { return UpdateTask(ctx, task) }
+func (SQLiteStore) DeleteTask(ctx context.Context, id string) error       { return DeleteTask(ctx, id) }
+
+func (SQLiteStore) UpdateDate(ctx context.Context, next string, id string) error {
+	return UpdateDate(ctx, next, id)
+}
+
+func (SQLiteStore) Tasks(ctx context.Context, after Cursor, limit int) ([]*Task, error) {
+	return Tasks(ctx, after, limit)
+}
+
+func (SQLiteStore) CountTasks(ctx context.Context) (int, error) { return CountTasks(ctx) }
+
+func (SQLiteStore) GetTasksByDate(ctx context.Context, date string, after Cursor, limit int) ([]*Task, error) {
+	return GetTasksByDate(ctx, date, after, limit)
+}
+
+func (SQLiteStore) CountTasksByDate(ctx context.Context, date string) (int, error) {
+	return CountTasksByDate(ctx, date)
+}
+
+func (SQLiteStore) TasksInRange(ctx context.Context, from, to string, after Cursor, limit int) ([]*Task, error) {
+	return TasksInRange(ctx, from, to, after, limit)
+}
+
+func (SQLiteStore) CountTasksInRange(ctx context.Context, from, to string) (int, error) {
+	return CountTasksInRange(ctx, from, to)
+}
+
+func (SQLiteStore) AgendaTasks(ctx context.Context, from, to string) ([]*Task, error) {
+	return AgendaTasks(ctx, from, to)
+}
+
+func (SQLiteStore) CountTasksByState(ctx context.Context, today string) (*TaskCounts, error) {
+	return CountTasksByState(ctx, today)
+}
+
+func (SQLiteStore) SearchTasks(ctx context.Context, q *Query, after Cursor, limit int) ([]*SearchResult, error) {
+	return SearchTasks(ctx, q, after, limit)
+}
+
+func (SQLiteStore) CountSearchTasks(ctx context.Context, q *Query) (int, error) {
+	return CountSearchTasks(ctx, q)
+}
+
+func (SQLiteStore) Ping(ctx context.Context) error { return Ping(ctx) }
+
+func (SQLiteStore) SchemaVersion(ctx context.Context) (int, error) { return SchemaVersion(ctx) }
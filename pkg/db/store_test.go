@@ -0,0 +1,377 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	newStore := func(t *testing.T) TaskStore {
+		require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+		return SQLiteStore{}
+	}
+	testTaskStore(t, newStore)
+	testFullTextSearch(t, newStore)
+}
+
+func TestMemoryStore(t *testing.T) {
+	newStore := func(*testing.T) TaskStore {
+		return NewMemoryStore()
+	}
+	testTaskStore(t, newStore)
+	testFullTextSearch(t, newStore)
+}
+
+// testTaskStore is the conformance suite every TaskStore has to pass.
+func testTaskStore(t *testing.T, newStore func(t *testing.T) TaskStore) {
+	ids := func(tasks []*Task) []int64 {
+		list := make([]int64, len(tasks))
+		for i, task := range tasks {
+			list[i] = task.ID
+		}
+		return list
+	}
+
+	t.Run("CRUD", func(t *testing.T) {
+		s := newStore(t)
+		ctx := t.Context()
+		id, err := s.AddTask(ctx, &Task{Date: "20261019", Title: "Отчёт", Comment: "квартальный", Repeat: "d 7"})
+		require.NoError(t, err)
+		assert.Positive(t, id)
+		sid := strconv.FormatInt(id, 10)
+
+		task, err := s.GetTask(ctx, sid)
+		require.NoError(t, err)
+		assert.Equal(t, &Task{ID: id, Date: "20261019", Title: "Отчёт", Comment: "квартальный", Repeat: "d 7"}, task)
+
+		task.Title = "Годовой отчёт"
+		require.NoError(t, s.UpdateTask(ctx, task))
+		require.NoError(t, s.UpdateDate(ctx, "20261026", sid))
+		task, err = s.GetTask(ctx, sid)
+		require.NoError(t, err)
+		assert.Equal(t, "Годовой отчёт", task.Title)
+		assert.Equal(t, "20261026", task.Date)
+
+		require.NoError(t, s.DeleteTask(ctx, sid))
+		_, err = s.GetTask(ctx, sid)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, s.DeleteTask(ctx, sid), ErrNotFound)
+		assert.ErrorIs(t, s.UpdateDate(ctx, "20261026", sid), ErrNotFound)
+		assert.ErrorIs(t, s.UpdateTask(ctx, &Task{ID: id, Date: "20261019", Title: "x"}), ErrNotFound)
+		_, err = s.GetTask(ctx, "abc")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		// Ids are never reused.
+		next, err := s.AddTask(ctx, &Task{Date: "20261019", Title: "Следующая"})
+		require.NoError(t, err)
+		assert.Greater(t, next, id)
+	})
+
+	t.Run("Constraints", func(t *testing.T) {
+		s := newStore(t)
+		ctx := t.Context()
+		for _, task := range []*Task{
+			{Date: "2026101", Title: "короткая дата"},
+			{Date: "20261019", Title: strings.Repeat("я", 256)},
+			{Date: "20261019", Title: "правило", Repeat: strings.Repeat("d", 129)},
+		} {
+			_, err := s.AddTask(ctx, task)
+			assert.Error(t, err, task.Title)
+		}
+		_, err := s.AddTask(ctx, &Task{Date: "20261019", Title: strings.Repeat("я", 255)})
+		assert.NoError(t, err)
+		count, err := s.CountTasks(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Lists", func(t *testing.T) {
+		s := newStore(t)
+		ctx := t.Context()
+		var all []int64
+		for _, task := range []Task{
+			{Date: "20261020", Title: "b"},
+			{Date: "20261019", Title: "a"},
+			{Date: "20261020", Title: "c", Repeat: "d 1"},
+			{Date: "20261101", Title: "d"},
+			{Date: "20261001", Title: "e", Repeat: "y"},
+			{Date: "20261001", Title: "f"},
+		} {
+			id, err := s.AddTask(ctx, &task)
+			require.NoError(t, err)
+			all = append(all, id)
+		}
+		ordered := []int64{all[4], all[5], all[1], all[0], all[2], all[3]}
+
+		tasks, err := s.Tasks(ctx, Cursor{}, -1)
+		require.NoError(t, err)
+		assert.Equal(t, ordered, ids(tasks))
+		count, err := s.CountTasks(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 6, count)
+
+		var paged []int64
+		after := Cursor{}
+		for {
+			page, err := s.Tasks(ctx, after, 4)
+			require.NoError(t, err)
+			paged = append(paged, ids(page)...)
+			if len(page) < 4 {
+				break
+			}
+			last := page[len(page)-1]
+			after = Cursor{Date: last.Date, ID: last.ID}
+		}
+		assert.Equal(t, ordered, paged)
+
+		tasks, err = s.GetTasksByDate(ctx, "20261020", Cursor{}, -1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{all[0], all[2]}, ids(tasks))
+		tasks, err = s.GetTasksByDate(ctx, "20261020", Cursor{Date: "20261020", ID: all[0]}, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{all[2]}, ids(tasks))
+		count, err = s.CountTasksByDate(ctx, "20261020")
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		for _, r := range []struct {
+			from, to string
+			want     []int64
+		}{
+			{"20261019", "20261020", []int64{all[1], all[0], all[2]}},
+			{"20261020", "", []int64{all[0], all[2], all[3]}},
+			{"", "20261001", []int64{all[4], all[5]}},
+			{"", "", ordered},
+		} {
+			tasks, err := s.TasksInRange(ctx, r.from, r.to, Cursor{}, -1)
+			require.NoError(t, err)
+			assert.Equal(t, r.want, ids(tasks), "%s-%s", r.from, r.to)
+			count, err := s.CountTasksInRange(ctx, r.from, r.to)
+			require.NoError(t, err)
+			assert.Equal(t, len(r.want), count)
+		}
+		tasks, err = s.TasksInRange(ctx, "", "", Cursor{}, 0)
+		require.NoError(t, err)
+		assert.Empty(t, tasks)
+
+		tasks, err = s.AgendaTasks(ctx, "20261020", "20261031")
+		require.NoError(t, err)
+		assert.Equal(t, []int64{all[4], all[0], all[2]}, ids(tasks))
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		s := newStore(t)
+		ctx := t.Context()
+		var all []int64
+		for _, task := range []Task{
+			{Date: "20261019", Title: "Квартальный отчёт", Repeat: "m 1"},
+			{Date: "20261020", Title: "Созвон", Comment: "обсудить план"},
+			{Date: "20261021", Title: "Deploy", Repeat: "d 7"},
+			{Date: "20261022", Title: "Обед"},
+		} {
+			id, err := s.AddTask(ctx, &task)
+			require.NoError(t, err)
+			all = append(all, id)
+		}
+
+		search := func(query string, after Cursor, limit int) []int64 {
+			q, err := ParseQuery(query, time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, err)
+			require.False(t, q.Ranked(), query)
+			results, err := s.SearchTasks(ctx, q, after, limit)
+			require.NoError(t, err)
+			count, err := s.CountSearchTasks(ctx, q)
+			require.NoError(t, err)
+			list := make([]int64, len(results))
+			for i, r := range results {
+				list[i] = r.ID
+			}
+			if after.IsZero() && limit < 0 {
+				assert.Len(t, list, count, query)
+			}
+			return list
+		}
+
+		assert.Equal(t, []int64{all[0], all[2]}, search("repeat:yes", Cursor{}, -1))
+		assert.Equal(t, []int64{all[1], all[3]}, search("repeat:no", Cursor{}, -1))
+		assert.Equal(t, []int64{all[2]}, search("repeat:D", Cursor{}, -1))
+		assert.Equal(t, []int64{all[3]}, search("after:today", Cursor{}, -1))
+		assert.Equal(t, []int64{all[1], all[2]}, search("after:20261019 before:20261022", Cursor{}, -1))
+		assert.Equal(t, []int64{all[0], all[2], all[3]}, search("-on:20261020", Cursor{}, -1))
+		assert.Equal(t, []int64{all[0], all[2]}, search("-title:созвон -title:обед", Cursor{}, -1))
+		assert.Equal(t, []int64{all[0], all[2], all[3]}, search("-comment:план", Cursor{}, -1))
+		assert.Equal(t, []int64{all[2], all[3]}, search("-title:отчет", Cursor{Date: "20261020", ID: all[1]}, 5))
+	})
+
+	t.Run("CountByState", func(t *testing.T) {
+		s := newStore(t)
+		ctx := t.Context()
+		counts, err := s.CountTasksByState(ctx, "20261019")
+		require.NoError(t, err)
+		assert.Equal(t, &TaskCounts{}, counts)
+
+		for _, task := range []Task{
+			{Date: "20261001", Title: "Просрочено"},
+			{Date: "20261018", Title: "Просрочено"},
+			{Date: "20261019", Title: "Сегодня"},
+			{Date: "20261101", Title: "Потом"},
+			{Date: "20261001", Title: "Каждый день", Repeat: "d 1"},
+		} {
+			_, err := s.AddTask(ctx, &task)
+			require.NoError(t, err)
+		}
+		counts, err = s.CountTasksByState(ctx, "20261019")
+		require.NoError(t, err)
+		assert.Equal(t, &TaskCounts{Overdue: 2, Today: 1, Future: 1, Repeating: 1}, counts)
+	})
+}
+
+// testFullTextSearch covers ranking, highlights and token matching of the
+// stores with a full-text index.
+func testFullTextSearch(t *testing.T, newStore func(t *testing.T) TaskStore) {
+	t.Run("Search", func(t *testing.T) {
+		s := newStore(t)
+		ctx := t.Context()
+		var all []int64
+		for _, task := range []Task{
+			{Date: "20261019", Title: "Квартальный отчёт", Comment: "собрать цифры", Repeat: "m 1"},
+			{Date: "20261020", Title: "Созвон", Comment: "обсудить отчет и план"},
+			{Date: "20261021", Title: "Deploy", Comment: "выкатить релиз на café-сервер", Repeat: "d 7"},
+			{Date: "20261022", Title: "Отчетность", Comment: "черновик"},
+			{Date: "20261023", Title: "Обед", Comment: "Долгий комментарий про то, что надо сделать в пятницу. Потом отправить отчёт коллегам и не забыть про цифры."},
+		} {
+			id, err := s.AddTask(ctx, &task)
+			require.NoError(t, err)
+			all = append(all, id)
+		}
+
+		search := func(query string, after Cursor, limit int) []*SearchResult {
+			q, err := ParseQuery(query, time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, err)
+			results, err := s.SearchTasks(ctx, q, after, limit)
+			require.NoError(t, err)
+			count, err := s.CountSearchTasks(ctx, q)
+			require.NoError(t, err)
+			if after.IsZero() && limit < 0 {
+				assert.Len(t, results, count, query)
+			}
+			return results
+		}
+		resultIDs := func(results []*SearchResult) []int64 {
+			list := make([]int64, len(results))
+			for i, r := range results {
+				list[i] = r.ID
+			}
+			return list
+		}
+
+		// Title matches weigh more than comment ones.
+		results := search("отчет", Cursor{}, -1)
+		assert.Equal(t, []int64{all[0], all[1], all[4]}, resultIDs(results))
+		assert.Equal(t, "Квартальный "+HighlightStart+"отчёт"+HighlightEnd, results[0].Highlight)
+		assert.Equal(t, "обсудить "+HighlightStart+"отчет"+HighlightEnd+" и план", results[1].Snippet)
+		assert.Equal(t, "…Потом отправить "+HighlightStart+"отчёт"+HighlightEnd+" коллегам и не забыть про цифры.", results[2].Snippet)
+		for i := 1; i < len(results); i++ {
+			assert.LessOrEqual(t, results[i-1].Rank, results[i].Rank)
+		}
+
+		var paged []int64
+		after := Cursor{}
+		for {
+			page := search("отчет", after, 2)
+			paged = append(paged, resultIDs(page)...)
+			if len(page) < 2 {
+				break
+			}
+			last := page[len(page)-1]
+			after = Cursor{Rank: last.Rank, ID: last.ID}
+		}
+		assert.Equal(t, resultIDs(results), paged)
+
+		assert.ElementsMatch(t, []int64{all[0], all[1], all[3], all[4]}, resultIDs(search("отчет*", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[0]}, resultIDs(search("title:отчет", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[1], all[4]}, resultIDs(search("comment:отчёт", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[1]}, resultIDs(search(`"отчет и план"`, Cursor{}, -1)))
+		assert.Equal(t, []int64{all[2]}, resultIDs(search("cafe DEPLOY", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[0], all[4]}, resultIDs(search("отчет -план", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[0], all[4]}, resultIDs(search("цифры", Cursor{}, -1)))
+
+		// Without positive text terms results are ordered by date.
+		assert.Equal(t, []int64{all[0], all[2]}, resultIDs(search("repeat:yes", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[2]}, resultIDs(search("repeat:d", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[3], all[4]}, resultIDs(search("after:today -comment:Deploy", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[3]}, resultIDs(search("-отчет before:20261023 after:20261019 -on:20261021", Cursor{}, -1)))
+		assert.Equal(t, []int64{all[3], all[4]}, resultIDs(search("-title:созвон after:20261021", Cursor{Date: "20261021", ID: all[2]}, 5)))
+		assert.Empty(t, search("несуществующее", Cursor{}, -1))
+	})
+}
+
+func TestQueryTimeout(t *testing.T) {
+	require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	_, err := AddTask(t.Context(), &Task{Date: "20261019", Title: "Отчёт"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = Tasks(ctx, Cursor{}, -1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	defer func(timeout time.Duration) { QueryTimeout = timeout }(QueryTimeout)
+	QueryTimeout = time.Nanosecond
+	_, err = Tasks(t.Context(), Cursor{}, -1)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = GetTask(t.Context(), "1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClose(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "scheduler.db")
+	require.NoError(t, Init(dbFile))
+
+	// Every connection of the pool is in WAL mode and waits for locks.
+	conns := make([]*sql.Conn, 2)
+	for i := range conns {
+		conn, err := db.Conn(t.Context())
+		require.NoError(t, err)
+		defer conn.Close()
+		conns[i] = conn
+	}
+	for _, conn := range conns {
+		var mode string
+		var timeout int
+		require.NoError(t, conn.QueryRowContext(t.Context(), `PRAGMA journal_mode`).Scan(&mode))
+		require.NoError(t, conn.QueryRowContext(t.Context(), `PRAGMA busy_timeout`).Scan(&timeout))
+		assert.Equal(t, "wal", mode)
+		assert.Equal(t, int(busyTimeout.Milliseconds()), timeout)
+		conn.Close()
+	}
+
+	id, err := AddTask(t.Context(), &Task{Date: "20261019", Title: "Отчёт"})
+	require.NoError(t, err)
+	wal, err := os.Stat(dbFile + "-wal")
+	require.NoError(t, err)
+	assert.Positive(t, wal.Size())
+	require.NoError(t, Close())
+
+	// The checkpoint moved everything into the database file.
+	if wal, err := os.Stat(dbFile + "-wal"); err == nil {
+		assert.Zero(t, wal.Size())
+	}
+
+	_, err = GetTask(t.Context(), strconv.FormatInt(id, 10))
+	assert.Error(t, err)
+
+	require.NoError(t, Init(dbFile))
+	task, err := GetTask(t.Context(), strconv.FormatInt(id, 10))
+	require.NoError(t, err)
+	assert.Equal(t, "Отчёт", task.Title)
+}
@@ -1,6 +1,16 @@
 package db
 
-import "fmt"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned for operations on a task, or a record of one, that
+// does not exist.
+var ErrNotFound = errors.New("not found")
 
 type Task struct {
 	ID      int64  `db:"id" json:"id"`
@@ -10,28 +20,55 @@ type Task struct {
 	Repeat  string `db:"repeat" json:"repeat"`
 }
 
-func AddTask(task *Task) (int64, error) {
+// Cursor points at the last task of a page in (date, id) order, or in
+// (rank, id) order for full-text search results.
+type Cursor struct {
+	Date string
+	Rank float64
+	ID   int64
+}
+
+func (c Cursor) IsZero() bool {
+	return c.Date == "" && c.Rank == 0 && c.ID == 0
+}
+
+func AddTask(ctx context.Context, task *Task) (int64, error) {
+	defer observe("AddTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`
-	res, err := db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat)
+	res, err := db.ExecContext(ctx, query, task.Date, task.Title, task.Comment, task.Repeat)
 	if err != nil {
 		return 0, fmt.Errorf("failed to add task: %w", err)
 	}
 	return res.LastInsertId()
 }
 
-func GetTask(id string) (*Task, error) {
+func GetTask(ctx context.Context, id string) (*Task, error) {
+	defer observe("GetTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	var task Task
 	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`
-	err := db.QueryRow(query, id).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	err := db.QueryRowContext(ctx, query, id).Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("task with id %s: %w", id, ErrNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get task: %w", err)
 	}
 	return &task, nil
 }
 
-func UpdateTask(task *Task) error {
+func UpdateTask(ctx context.Context, task *Task) error {
+	defer observe("UpdateTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`
-	res, err := db.Exec(query, task.Date, task.Title, task.Comment, task.Repeat, task.ID)
+	res, err := db.ExecContext(ctx, query, task.Date, task.Title, task.Comment, task.Repeat, task.ID)
 	if err != nil {
 		return fmt.Errorf("failed to update task: %w", err)
 	}
@@ -40,38 +77,18 @@ func UpdateTask(task *Task) error {
 		return fmt.Errorf("failed to get rows affected after update: %w", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("incorrect id for updating task")
+		return fmt.Errorf("incorrect id for updating task: %w", ErrNotFound)
 	}
 	return nil
 }
 
-func Tasks(limit int) ([]*Task, error) {
-	rows, err := db.Query("SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date ASC LIMIT ?", limit)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query tasks: %w", err)
-	}
-	defer rows.Close()
+func DeleteTask(ctx context.Context, id string) error {
+	defer observe("DeleteTask")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	tasks := make([]*Task, 0)
-	for rows.Next() {
-		var task Task
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan task row: %w", err)
-		}
-		tasks = append(tasks, &task)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over task rows: %w", err)
-	}
-
-	return tasks, nil
-}
-
-func DeleteTask(id string) error {
 	query := `DELETE FROM scheduler WHERE id = ?`
-	res, err := db.Exec(query, id)
+	res, err := db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete task: %w", err)
 	}
@@ -80,14 +97,18 @@ func DeleteTask(id string) error {
 		return fmt.Errorf("failed to get rows affected after delete: %w", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("task with id %s not found", id)
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
 	}
 	return nil
 }
 
-func UpdateDate(next string, id string) error {
+func UpdateDate(ctx context.Context, next string, id string) error {
+	defer observe("UpdateDate")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE scheduler SET date = ? WHERE id = ?`
-	res, err := db.Exec(query, next, id)
+	res, err := db.ExecContext(ctx, query, next, id)
 	if err != nil {
 		return fmt.Errorf("failed to update task date: %w", err)
 	}
@@ -96,16 +117,115 @@ func UpdateDate(next string, id string) error {
 		return fmt.Errorf("failed to get rows affected after date update: %w", err)
 	}
 	if count == 0 {
-		return fmt.Errorf("task with id %s not found", id)
+		return fmt.Errorf("task with id %s: %w", id, ErrNotFound)
 	}
 	return nil
 }
 
-func SearchTasks(searchText string, limit int) ([]*Task, error) {
-	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE title LIKE ? OR comment LIKE ? ORDER BY date LIMIT ?`
-	rows, err := db.Query(query, "%"+searchText+"%", "%"+searchText+"%", limit)
+func Tasks(ctx context.Context, after Cursor, limit int) ([]*Task, error) {
+	defer observe("Tasks")()
+	return listTasks(ctx, "", nil, after, limit)
+}
+
+func CountTasks(ctx context.Context) (int, error) {
+	defer observe("CountTasks")()
+	return countTasks(ctx, "", nil)
+}
+
+func GetTasksByDate(ctx context.Context, date string, after Cursor, limit int) ([]*Task, error) {
+	defer observe("GetTasksByDate")()
+	return listTasks(ctx, "date = ?", []any{date}, after, limit)
+}
+
+func CountTasksByDate(ctx context.Context, date string) (int, error) {
+	defer observe("CountTasksByDate")()
+	return countTasks(ctx, "date = ?", []any{date})
+}
+
+// TasksInRange lists tasks with from <= date <= to; an empty bound is not applied.
+func TasksInRange(ctx context.Context, from, to string, after Cursor, limit int) ([]*Task, error) {
+	defer observe("TasksInRange")()
+	where, args := dateRange(from, to)
+	return listTasks(ctx, where, args, after, limit)
+}
+
+func CountTasksInRange(ctx context.Context, from, to string) (int, error) {
+	defer observe("CountTasksInRange")()
+	where, args := dateRange(from, to)
+	return countTasks(ctx, where, args)
+}
+
+// AgendaTasks returns every task that can have an occurrence in [from, to]:
+// one-off tasks dated inside the range and repeating tasks dated up to its end.
+func AgendaTasks(ctx context.Context, from, to string) ([]*Task, error) {
+	defer observe("AgendaTasks")()
+	return listTasks(ctx, agendaWhere, []any{to, from}, Cursor{}, -1)
+}
+
+// TaskCounts counts tasks by state relative to a day. Repeating tasks are
+// counted apart, as their date is only the next occurrence.
+type TaskCounts struct {
+	Overdue, Today, Future, Repeating int
+}
+
+func CountTasksByState(ctx context.Context, today string) (*TaskCounts, error) {
+	defer observe("CountTasksByState")()
+	return countByState(ctx, db, sqliteDialect, today)
+}
+
+const agendaWhere = "date <= ? AND (repeat <> '' OR date >= ?)"
+
+func dateRange(from, to string) (string, []any) {
+	switch {
+	case from != "" && to != "":
+		return "date BETWEEN ? AND ?", []any{from, to}
+	case from != "":
+		return "date >= ?", []any{from}
+	case to != "":
+		return "date <= ?", []any{to}
+	default:
+		return "", nil
+	}
+}
+
+func listTasks(ctx context.Context, where string, whereArgs []any, after Cursor, limit int) ([]*Task, error) {
+	return queryTasks(ctx, db, sqliteDialect, where, whereArgs, after, limit)
+}
+
+func countTasks(ctx context.Context, where string, args []any) (int, error) {
+	return countRows(ctx, db, sqliteDialect, where, args)
+}
+
+// queryTasks returns up to limit tasks matching the where clause, ordered by
+// (date, id) and starting right after the cursor. A negative limit means no
+// limit.
+func queryTasks(ctx context.Context, conn *sql.DB, d dialect, where string, whereArgs []any, after Cursor, limit int) ([]*Task, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var conds []string
+	args := append([]any{}, whereArgs...)
+	if where != "" {
+		conds = append(conds, where)
+	}
+	if !after.IsZero() {
+		conds = append(conds, "(date > ? OR (date = ? AND id > ?))")
+		args = append(args, after.Date, after.Date, after.ID)
+	}
+
+	query := `SELECT id, date, title, comment, repeat FROM scheduler`
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, " AND ")
+	}
+	query += ` ORDER BY date, id`
+	if limit >= 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := conn.QueryContext(ctx, d.bind(query), args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to search tasks: %w", err)
+		return nil, fmt.Errorf("failed to query tasks: %w", err)
 	}
 	defer rows.Close()
 
@@ -114,39 +234,49 @@ func SearchTasks(searchText string, limit int) ([]*Task, error) {
 		var task Task
 		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan search task row: %w", err)
+			return nil, fmt.Errorf("failed to scan task row: %w", err)
 		}
 		tasks = append(tasks, &task)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over search task rows: %w", err)
+		return nil, fmt.Errorf("error iterating over task rows: %w", err)
 	}
 
 	return tasks, nil
 }
 
-func GetTasksByDate(date string, limit int) ([]*Task, error) {
-	query := `SELECT id, date, title, comment, repeat FROM scheduler WHERE date = ? ORDER BY date LIMIT ?`
-	rows, err := db.Query(query, date, limit)
+func countByState(ctx context.Context, conn *sql.DB, d dialect, today string) (*TaskCounts, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT
+		COALESCE(SUM(CASE WHEN repeat = '' AND date < ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN repeat = '' AND date = ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN repeat = '' AND date > ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN repeat <> '' THEN 1 ELSE 0 END), 0)
+		FROM scheduler`
+
+	var c TaskCounts
+	err := conn.QueryRowContext(ctx, d.bind(query), today, today, today).Scan(&c.Overdue, &c.Today, &c.Future, &c.Repeating)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get tasks by date: %w", err)
+		return nil, fmt.Errorf("failed to count tasks by state: %w", err)
 	}
-	defer rows.Close()
+	return &c, nil
+}
 
-	tasks := make([]*Task, 0)
-	for rows.Next() {
-		var task Task
-		err := rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan task by date row: %w", err)
-		}
-		tasks = append(tasks, &task)
-	}
+func countRows(ctx context.Context, conn *sql.DB, d dialect, where string, args []any) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over tasks by date rows: %w", err)
+	query := `SELECT COUNT(*) FROM scheduler`
+	if where != "" {
+		query += ` WHERE ` + where
 	}
 
-	return tasks, nil
+	var count int
+	if err := conn.QueryRowContext(ctx, d.bind(query), args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count tasks: %w", err)
+	}
+	return count, nil
 }
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrTimerRunning = errors.New("another timer is already running")
+
+// ErrNoRunningTimer is returned by StopTimer when no timer is running.
+var ErrNoRunningTimer = errors.New("no running timer")
+
+// TimeEntry is a piece of work logged against a task. Occurrence is the task
+// date at the moment the work was logged, so time spent on a repeating task
+// stays attributed to that occurrence after the task moves to its next date.
+// Day is the date the work was done on; StartedAt and StoppedAt are unix
+// seconds, StoppedAt is nil while the timer is running.
+type TimeEntry struct {
+	ID         int64  `db:"id" json:"id"`
+	TaskID     int64  `db:"task_id" json:"task_id"`
+	Occurrence string `db:"occurrence" json:"occurrence"`
+	Day        string `db:"day" json:"day"`
+	StartedAt  int64  `db:"started_at" json:"started_at"`
+	StoppedAt  *int64 `db:"stopped_at" json:"stopped_at"`
+	Duration   int64  `db:"duration" json:"duration"`
+	Comment    string `db:"comment" json:"comment"`
+}
+
+type TimeTotal struct {
+	TaskID     int64  `db:"task_id" json:"task_id"`
+	Title      string `db:"title" json:"title"`
+	Occurrence string `db:"occurrence" json:"occurrence"`
+	Duration   int64  `db:"duration" json:"duration"`
+}
+
+const timeEntryColumns = `id, task_id, occurrence, day, started_at, stopped_at, duration, comment`
+
+func scanTimeEntry(row interface{ Scan(...any) error }, e *TimeEntry) error {
+	return row.Scan(&e.ID, &e.TaskID, &e.Occurrence, &e.Day, &e.StartedAt, &e.StoppedAt, &e.Duration, &e.Comment)
+}
+
+// StartTimer starts a timer for the task, taking its current date as the occurrence.
+func StartTimer(ctx context.Context, taskID string, day string, now int64) (int64, error) {
+	defer observe("StartTimer")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO time_entries (task_id, occurrence, day, started_at)
+SELECT id, date, ?, ? FROM scheduler WHERE id = ?`
+	res, err := db.ExecContext(ctx, query, day, now, taskID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return 0, ErrTimerRunning
+		}
+		return 0, fmt.Errorf("failed to start timer: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected after timer start: %w", err)
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("task with id %s: %w", taskID, ErrNotFound)
+	}
+	return res.LastInsertId()
+}
+
+// StopTimer stops the running timer and returns the finished entry.
+func StopTimer(ctx context.Context, now int64) (*TimeEntry, error) {
+	defer observe("StopTimer")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	// Reading and stopping the timer is one statement, so that of two
+	// concurrent stops only one succeeds.
+	var e TimeEntry
+	query := `UPDATE time_entries SET stopped_at = ?1, duration = MAX(0, ?1 - started_at)
+WHERE stopped_at IS NULL RETURNING ` + timeEntryColumns
+	err := scanTimeEntry(db.QueryRowContext(ctx, query, now), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoRunningTimer
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop timer: %w", err)
+	}
+	return &e, nil
+}
+
+// RunningTimer returns the running timer or nil if there is none.
+func RunningTimer(ctx context.Context) (*TimeEntry, error) {
+	defer observe("RunningTimer")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var e TimeEntry
+	query := `SELECT ` + timeEntryColumns + ` FROM time_entries WHERE stopped_at IS NULL`
+	err := scanTimeEntry(db.QueryRowContext(ctx, query), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get running timer: %w", err)
+	}
+	return &e, nil
+}
+
+func GetTimeEntry(ctx context.Context, id int64) (*TimeEntry, error) {
+	defer observe("GetTimeEntry")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var e TimeEntry
+	query := `SELECT ` + timeEntryColumns + ` FROM time_entries WHERE id = ?`
+	err := scanTimeEntry(db.QueryRowContext(ctx, query, id), &e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time entry: %w", err)
+	}
+	return &e, nil
+}
+
+// AddTimeEntry logs a finished piece of work against the current occurrence of the task.
+func AddTimeEntry(ctx context.Context, e *TimeEntry) (int64, error) {
+	defer observe("AddTimeEntry")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `INSERT INTO time_entries (task_id, occurrence, day, started_at, stopped_at, duration, comment)
+SELECT id, date, ?, ?, ?, ?, ? FROM scheduler WHERE id = ?`
+	res, err := db.ExecContext(ctx, query, e.Day, e.StartedAt, e.StoppedAt, e.Duration, e.Comment, e.TaskID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add time entry: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected after time entry insert: %w", err)
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("task with id %d: %w", e.TaskID, ErrNotFound)
+	}
+	return res.LastInsertId()
+}
+
+func DeleteTimeEntry(ctx context.Context, id string) error {
+	defer observe("DeleteTimeEntry")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM time_entries WHERE id = ?`
+	res, err := db.ExecContext(ctx, query, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete time entry: %w", err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected after time entry delete: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("time entry with id %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+// TimeEntries lists entries filtered by task (empty for all tasks) and by
+// work day range [from, to]; empty bounds are not applied.
+func TimeEntries(ctx context.Context, taskID, from, to string) ([]*TimeEntry, error) {
+	defer observe("TimeEntries")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	where, args := timeEntryFilter(taskID, from, to)
+	query := `SELECT ` + timeEntryColumns + ` FROM time_entries` + where + ` ORDER BY day, started_at, id`
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query time entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*TimeEntry, 0)
+	for rows.Next() {
+		var e TimeEntry
+		if err := scanTimeEntry(rows, &e); err != nil {
+			return nil, fmt.Errorf("failed to scan time entry row: %w", err)
+		}
+		entries = append(entries, &e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over time entry rows: %w", err)
+	}
+
+	return entries, nil
+}
+
+// TimeTotals sums finished entries per task and occurrence with the same
+// filters as TimeEntries.
+func TimeTotals(ctx context.Context, taskID, from, to string) ([]*TimeTotal, error) {
+	defer observe("TimeTotals")()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	where, args := timeEntryFilter(taskID, from, to)
+	query := `SELECT e.task_id, COALESCE(s.title, ''), e.occurrence, SUM(e.duration)
+FROM (SELECT * FROM time_entries` + where + `) e LEFT JOIN scheduler s ON s.id = e.task_id
+WHERE e.stopped_at IS NOT NULL
+GROUP BY e.task_id, e.occurrence
+ORDER BY e.task_id, e.occurrence`
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query time totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make([]*TimeTotal, 0)
+	for rows.Next() {
+		var t TimeTotal
+		if err := rows.Scan(&t.TaskID, &t.Title, &t.Occurrence, &t.Duration); err != nil {
+			return nil, fmt.Errorf("failed to scan time total row: %w", err)
+		}
+		totals = append(totals, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over time total rows: %w", err)
+	}
+
+	return totals, nil
+}
+
+func timeEntryFilter(taskID, from, to string) (string, []any) {
+	var conds []string
+	var args []any
+	if taskID != "" {
+		conds = append(conds, "task_id = ?")
+		args = append(args, taskID)
+	}
+	if from != "" {
+		conds = append(conds, "day >= ?")
+		args = append(args, from)
+	}
+	if to != "" {
+		conds = append(conds, "day <= ?")
+		args = append(args, to)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStopTimerOnce(t *testing.T) {
+	require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	taskID, err := AddTask(t.Context(), &Task{Date: "20261019", Title: "Таймер"})
+	require.NoError(t, err)
+	_, err = StartTimer(t.Context(), "1", "20261019", 1000)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), taskID)
+
+	// Of concurrent stops exactly one gets the entry.
+	const stops = 8
+	errs := make([]error, stops)
+	var wg sync.WaitGroup
+	for i := range stops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = StopTimer(t.Context(), 1300)
+		}()
+	}
+	wg.Wait()
+
+	stopped := 0
+	for _, err := range errs {
+		if err == nil {
+			stopped++
+		} else {
+			assert.ErrorIs(t, err, ErrNoRunningTimer)
+		}
+	}
+	assert.Equal(t, 1, stopped)
+
+	entries, err := TimeEntries(t.Context(), "1", "", "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, int64(300), entries[0].Duration)
+}
+
+func TestTimeEntryNotFound(t *testing.T) {
+	require.NoError(t, Init(filepath.Join(t.TempDir(), "scheduler.db")))
+
+	_, err := StartTimer(t.Context(), "42", "20261019", 1000)
+	assert.ErrorIs(t, err, ErrNotFound)
+	stopped := int64(1300)
+	_, err = AddTimeEntry(t.Context(), &TimeEntry{TaskID: 42, Day: "20261019", StartedAt: 1000, StoppedAt: &stopped, Duration: 300})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, DeleteTimeEntry(t.Context(), "42"), ErrNotFound)
+
+	// Other failures are not reported as missing records.
+	require.NoError(t, Close())
+	_, err = StartTimer(t.Context(), "42", "20261019", 1000)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+	assert.NotErrorIs(t, DeleteTimeEntry(t.Context(), "42"), ErrNotFound)
+}
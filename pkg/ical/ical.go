@@ -0,0 +1,273 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) used by
+// the calendar feed and import.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest content line allowed before folding.
+const maxLineOctets = 75
+
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is a calendar object such as VCALENDAR, VEVENT or VTODO.
+type Component struct {
+	Name       string
+	Props      []Property
+	Components []*Component
+}
+
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// Add appends a property with a raw value; params are name, value pairs.
+func (c *Component) Add(name, value string, params ...string) {
+	p := Property{Name: name, Value: value}
+	if len(params) > 0 {
+		p.Params = make(map[string]string, len(params)/2)
+		for i := 0; i+1 < len(params); i += 2 {
+			p.Params[params[i]] = params[i+1]
+		}
+	}
+	c.Props = append(c.Props, p)
+}
+
+// AddText appends a TEXT property, escaping the value.
+func (c *Component) AddText(name, value string) {
+	c.Add(name, EscapeText(value))
+}
+
+func (c *Component) AddComponent(child *Component) {
+	c.Components = append(c.Components, child)
+}
+
+// Encode writes the component with CRLF line endings and folded long lines.
+func (c *Component) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	c.encode(bw)
+	return bw.Flush()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Props {
+		writeLine(w, p.String())
+	}
+	for _, child := range c.Components {
+		child.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+func (p Property) String() string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+
+	names := make([]string, 0, len(p.Params))
+	for name := range p.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := p.Params[name]
+		if strings.ContainsAny(value, ":;,") {
+			value = `"` + value + `"`
+		}
+		b.WriteString(";" + name + "=" + value)
+	}
+
+	b.WriteString(":" + p.Value)
+	return b.String()
+}
+
+// writeLine folds the line into chunks of at most 75 octets without splitting
+// UTF-8 sequences; continuation lines start with a space.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line + "\r\n")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func EscapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// Get returns the first property with the name or nil.
+func (c *Component) Get(name string) *Property {
+	for i := range c.Props {
+		if c.Props[i].Name == name {
+			return &c.Props[i]
+		}
+	}
+	return nil
+}
+
+// Value returns the raw value of the first property with the name.
+func (c *Component) Value(name string) string {
+	if p := c.Get(name); p != nil {
+		return p.Value
+	}
+	return ""
+}
+
+// Text returns the unescaped value of the first TEXT property with the name.
+func (c *Component) Text(name string) string {
+	return UnescapeText(c.Value(name))
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";")
+
+func UnescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
+
+// SyntaxError reports a malformed content line.
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Decode reads the first top-level component, normally VCALENDAR. Both CRLF
+// and bare LF line endings are accepted.
+func Decode(r io.Reader) (*Component, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		stack  []*Component
+		line   string
+		lineNo int
+		start  int
+	)
+
+	// handle processes one unfolded content line.
+	handle := func() (*Component, error) {
+		if strings.TrimSpace(line) == "" {
+			return nil, nil
+		}
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, &SyntaxError{Line: start, Msg: err.Error()}
+		}
+		switch p.Name {
+		case "BEGIN":
+			c := NewComponent(strings.ToUpper(p.Value))
+			if len(stack) > 0 {
+				stack[len(stack)-1].AddComponent(c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, &SyntaxError{Line: start, Msg: "unexpected END:" + p.Value}
+			}
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return c, nil
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, &SyntaxError{Line: start, Msg: "property outside of a component"}
+			}
+			c := stack[len(stack)-1]
+			c.Props = append(c.Props, p)
+		}
+		return nil, nil
+	}
+
+	for scanner.Scan() {
+		lineNo++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			line += text[1:]
+			continue
+		}
+		if c, err := handle(); c != nil || err != nil {
+			return c, err
+		}
+		line, start = text, lineNo
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if c, err := handle(); c != nil || err != nil {
+		return c, err
+	}
+	return nil, &SyntaxError{Line: lineNo, Msg: "unexpected end of file"}
+}
+
+// parseProperty splits a content line into its name, parameters and value.
+// Parameter values may be quoted and contain ":" and ";".
+func parseProperty(line string) (Property, error) {
+	var p Property
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, fmt.Errorf("invalid content line %q", line)
+	}
+	p.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return p, fmt.Errorf("invalid parameter in %q", line)
+		}
+		name := strings.ToUpper(rest[:eq])
+		j := eq + 1
+		var value string
+		if j < len(rest) && rest[j] == '"' {
+			end := strings.IndexByte(rest[j+1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("unterminated quote in %q", line)
+			}
+			value = rest[j+1 : j+1+end]
+			j += end + 2
+		} else {
+			end := strings.IndexAny(rest[j:], ";:")
+			if end < 0 {
+				return p, fmt.Errorf("missing value in %q", line)
+			}
+			value = rest[j : j+end]
+			j += end
+		}
+		if j >= len(rest) {
+			return p, fmt.Errorf("missing value in %q", line)
+		}
+		if p.Params == nil {
+			p.Params = make(map[string]string)
+		}
+		p.Params[name] = value
+		i += 1 + j
+	}
+
+	if line[i] != ':' {
+		return p, fmt.Errorf("invalid content line %q", line)
+	}
+	p.Value = line[i+1:]
+	return p, nil
+}
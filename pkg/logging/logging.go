@@ -0,0 +1,85 @@
+// Package logging builds the server's slog logger and carries request IDs in
+// contexts, so that every record logged with a request's context can be
+// matched to the access log line of that request. The contexts also carry
+// the authenticated user for the access log.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// New returns a logger writing "text" or "json" records to w. Records logged
+// with a context from WithRequestID get a request_id attribute.
+func New(w io.Writer, format string) (*slog.Logger, error) {
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(w, nil)
+	case "json":
+		h = slog.NewJSONHandler(w, nil)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	return slog.New(requestIDHandler{h}), nil
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID stored by WithRequestID or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type userKey struct{}
+
+// Anonymous is the user of requests that were not authenticated.
+const Anonymous = "anonymous"
+
+// WithUser returns a context in which SetUser can record who made the
+// request. Authentication happens inside the handler, while the access log
+// is written outside of it, so the user is kept in a slot shared by both.
+func WithUser(ctx context.Context) context.Context {
+	return context.WithValue(ctx, userKey{}, new(string))
+}
+
+// SetUser records the authenticated user in a context from WithUser.
+func SetUser(ctx context.Context, user string) {
+	if slot, ok := ctx.Value(userKey{}).(*string); ok {
+		*slot = user
+	}
+}
+
+// User returns the user recorded by SetUser or Anonymous if there is none.
+func User(ctx context.Context) string {
+	if slot, ok := ctx.Value(userKey{}).(*string); ok && *slot != "" {
+		return *slot
+	}
+	return Anonymous
+}
+
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,121 @@
+package reminder
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+// MaxAttempts is how many times delivery of a reminder is tried before it is
+// given up for the current date of its task.
+const MaxAttempts = 5
+
+// Dispatcher periodically delivers due reminders. Delivery state lives in the
+// database: a reminder is marked as sent only after the notifier succeeded.
+// Failed deliveries are retried after one interval, then two, four and so on,
+// up to MaxAttempts. Reminders that came due while the server was down are
+// sent once it is back, marked as late. Only a reminder whose time had
+// already passed when it was added is skipped for that task date.
+type Dispatcher struct {
+	Notifier Notifier
+	Interval time.Duration
+	Now      func() time.Time
+	Logger   *slog.Logger
+}
+
+func (d *Dispatcher) interval() time.Duration {
+	if d.Interval <= 0 {
+		return time.Minute
+	}
+	return d.Interval
+}
+
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval())
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.Dispatch(ctx); err != nil {
+			d.Logger.Error("error when dispatching reminders", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch sends every reminder that is due at the current time and returns
+// the number of delivered notifications.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := time.Now
+	if d.Now != nil {
+		now = d.Now
+	}
+
+	current := now()
+	due, err := db.DueReminders(ctx, current.Format("2006-01-02 15:04"))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range due {
+		if ctx.Err() != nil {
+			return sent, ctx.Err()
+		}
+
+		remindAt, _ := time.ParseInLocation("20060102 15:04", r.Task.Date+" "+r.Time, current.Location())
+		remindAt = remindAt.AddDate(0, 0, -r.OffsetDays)
+
+		// The outcome of a notification is saved even if the dispatcher is
+		// stopped meanwhile, so that a delivered reminder is not sent again.
+		saveCtx := context.WithoutCancel(ctx)
+
+		if remindAt.Format("2006-01-02 15:04") < r.CreatedAt {
+			d.Logger.Info("skipping reminder due before it was added", "reminder", r.ID, "remind_at", remindAt, "created_at", r.CreatedAt)
+			if err := db.GiveUpReminder(saveCtx, r.ID, r.Task.Date, "missed: the reminder time had passed when it was added"); err != nil {
+				return sent, err
+			}
+			continue
+		}
+
+		n := Notification{
+			TaskID:   r.Task.ID,
+			Date:     r.Task.Date,
+			Title:    r.Task.Title,
+			Comment:  r.Task.Comment,
+			Repeat:   r.Task.Repeat,
+			RemindAt: remindAt.Format(time.RFC3339),
+			// Fire times have minute precision, hence the extra minute.
+			Late: r.Attempts == 0 && current.Sub(remindAt) > d.interval()+time.Minute,
+		}
+
+		if err := d.Notifier.Notify(ctx, n); err != nil {
+			attempt := r.Attempts + 1
+			if attempt >= MaxAttempts {
+				d.Logger.Error("giving up reminder", "reminder", r.ID, "attempts", attempt, "error", err)
+				err = db.GiveUpReminder(saveCtx, r.ID, r.Task.Date, err.Error())
+			} else {
+				retryAt := current.Add(d.interval() << r.Attempts)
+				d.Logger.Error("error when sending reminder", "reminder", r.ID, "attempts", attempt, "retry_at", retryAt, "error", err)
+				err = db.MarkReminderFailed(saveCtx, r.ID, err.Error(), retryAt.Format("2006-01-02 15:04"))
+			}
+			if err != nil {
+				d.Logger.Error("error when saving reminder state", "reminder", r.ID, "error", err)
+			}
+			continue
+		}
+
+		if err := db.MarkReminderSent(saveCtx, r.ID, r.Task.Date); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+
+	return sent, nil
+}
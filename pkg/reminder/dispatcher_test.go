@@ -0,0 +1,248 @@
+package reminder
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+// smtpStub accepts mail on a local port and passes every message body to msgs.
+func smtpStub(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	msgs := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				rd := bufio.NewReader(conn)
+				io.WriteString(conn, "220 stub\r\n")
+				for {
+					line, err := rd.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+						io.WriteString(conn, "250 stub\r\n")
+					case cmd == "DATA":
+						io.WriteString(conn, "354 go ahead\r\n")
+						var msg strings.Builder
+						for {
+							l, err := rd.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if l == ".\r\n" {
+								break
+							}
+							msg.WriteString(l)
+						}
+						msgs <- msg.String()
+						io.WriteString(conn, "250 queued\r\n")
+					case cmd == "QUIT":
+						io.WriteString(conn, "221 bye\r\n")
+						return
+					default:
+						io.WriteString(conn, "250 OK\r\n")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), msgs
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(context.Context, Notification) error {
+	return errors.New("unavailable")
+}
+
+func TestDispatchSMTP(t *testing.T) {
+	require.NoError(t, db.Init(filepath.Join(t.TempDir(), "scheduler.db")))
+
+	taskID, err := db.AddTask(t.Context(), &db.Task{Date: "20261020", Title: "Отчёт", Comment: "квартальный", Repeat: "d 7"})
+	require.NoError(t, err)
+	_, err = db.AddReminder(t.Context(), &db.Reminder{TaskID: taskID, OffsetDays: 1, Time: "09:00"})
+	require.NoError(t, err)
+
+	addr, msgs := smtpStub(t)
+	clock := &fakeClock{now: time.Date(2026, 10, 19, 8, 59, 0, 0, time.Local)}
+	newDispatcher := func(n Notifier) *Dispatcher {
+		return &Dispatcher{
+			Notifier: n,
+			Now:      clock.Now,
+			Logger:   slog.New(slog.DiscardHandler),
+		}
+	}
+	smtpNotifier := &SMTPNotifier{Addr: addr, From: "scheduler@localhost", To: []string{"team@localhost"}}
+	ctx := context.Background()
+
+	sent, err := newDispatcher(smtpNotifier).Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	clock.now = clock.now.Add(time.Minute)
+	sent, err = newDispatcher(failingNotifier{}).Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// A failed reminder is retried one interval later.
+	sent, err = newDispatcher(smtpNotifier).Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	clock.now = clock.now.Add(time.Minute)
+	sent, err = newDispatcher(smtpNotifier).Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	select {
+	case msg := <-msgs:
+		assert.Contains(t, msg, "Отчёт")
+		assert.Contains(t, msg, "20.10.2026")
+		assert.NotContains(t, msg, "опозданием")
+	case <-time.After(5 * time.Second):
+		t.Fatal("smtp stub did not receive a message")
+	}
+
+	// A fresh dispatcher, as after a restart, must not send the reminder again.
+	sent, err = newDispatcher(smtpNotifier).Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// Moving a repeating task to its next date re-arms the reminder.
+	require.NoError(t, db.UpdateDate(t.Context(), "20261027", "1"))
+	clock.now = time.Date(2026, 10, 26, 9, 0, 0, 0, time.Local)
+	sent, err = newDispatcher(smtpNotifier).Dispatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	<-msgs
+}
+
+func TestDispatchGivesUp(t *testing.T) {
+	require.NoError(t, db.Init(filepath.Join(t.TempDir(), "scheduler.db")))
+
+	taskID, err := db.AddTask(t.Context(), &db.Task{Date: "20261020", Title: "Вебхук", Repeat: "d 7"})
+	require.NoError(t, err)
+	_, err = db.AddReminder(t.Context(), &db.Reminder{TaskID: taskID, Time: "09:00"})
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Date(2026, 10, 20, 9, 0, 0, 0, time.Local)}
+	d := &Dispatcher{Notifier: failingNotifier{}, Now: clock.Now, Logger: slog.New(slog.DiscardHandler)}
+
+	// Attempts follow after 1, 2, 4 and 8 minutes; then the reminder is
+	// given up for this date.
+	var tries []time.Duration
+	start := clock.now
+	for range 20 {
+		due, err := db.DueReminders(t.Context(), clock.now.Format("2006-01-02 15:04"))
+		require.NoError(t, err)
+		if len(due) > 0 {
+			tries = append(tries, clock.now.Sub(start))
+		}
+		_, err = d.Dispatch(t.Context())
+		require.NoError(t, err)
+		clock.now = clock.now.Add(time.Minute)
+	}
+	assert.Equal(t, []time.Duration{0, time.Minute, 3 * time.Minute, 7 * time.Minute, 15 * time.Minute}, tries)
+
+	reminders, err := db.GetReminders(t.Context(), "1")
+	require.NoError(t, err)
+	require.Len(t, reminders, 1)
+	assert.Equal(t, "20261020", reminders[0].FailedFor)
+	assert.Equal(t, "unavailable", reminders[0].LastError)
+
+	// The next date of the task gets a fresh start.
+	require.NoError(t, db.UpdateDate(t.Context(), "20261027", "1"))
+	clock.now = time.Date(2026, 10, 27, 9, 0, 0, 0, time.Local)
+	due, err := db.DueReminders(t.Context(), clock.now.Format("2006-01-02 15:04"))
+	require.NoError(t, err)
+	assert.Len(t, due, 1)
+}
+
+type recordingNotifier struct{ sent []Notification }
+
+func (n *recordingNotifier) Notify(_ context.Context, msg Notification) error {
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func TestDispatchSkipsPastAtCreation(t *testing.T) {
+	require.NoError(t, db.Init(filepath.Join(t.TempDir(), "scheduler.db")))
+
+	taskID, err := db.AddTask(t.Context(), &db.Task{Date: "20261001", Title: "Давно прошла"})
+	require.NoError(t, err)
+	_, err = db.AddReminder(t.Context(), &db.Reminder{TaskID: taskID, Time: "09:00", CreatedAt: "2026-10-19 12:00"})
+	require.NoError(t, err)
+
+	notifier := &recordingNotifier{}
+	clock := &fakeClock{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)}
+	d := &Dispatcher{Notifier: notifier, Now: clock.Now, Logger: slog.New(slog.DiscardHandler)}
+
+	sent, err := d.Dispatch(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Empty(t, notifier.sent)
+
+	due, err := db.DueReminders(t.Context(), clock.now.Format("2006-01-02 15:04"))
+	require.NoError(t, err)
+	assert.Empty(t, due)
+}
+
+func TestDispatchSendsMissedOnce(t *testing.T) {
+	require.NoError(t, db.Init(filepath.Join(t.TempDir(), "scheduler.db")))
+
+	taskID, err := db.AddTask(t.Context(), &db.Task{Date: "20261020", Title: "Пока сервер стоял"})
+	require.NoError(t, err)
+	_, err = db.AddReminder(t.Context(), &db.Reminder{TaskID: taskID, Time: "09:00", CreatedAt: "2026-10-19 12:00"})
+	require.NoError(t, err)
+
+	// The server was down from before 09:00 until the afternoon.
+	notifier := &recordingNotifier{}
+	clock := &fakeClock{now: time.Date(2026, 10, 20, 15, 30, 0, 0, time.Local)}
+	newDispatcher := func() *Dispatcher {
+		return &Dispatcher{Notifier: notifier, Now: clock.Now, Logger: slog.New(slog.DiscardHandler)}
+	}
+
+	sent, err := newDispatcher().Dispatch(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	if assert.Len(t, notifier.sent, 1) {
+		assert.True(t, notifier.sent[0].Late)
+	}
+
+	// Another restart does not send it again.
+	clock.now = clock.now.Add(time.Hour)
+	sent, err = newDispatcher().Dispatch(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Len(t, notifier.sent, 1)
+}
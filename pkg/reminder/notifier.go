@@ -0,0 +1,120 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Notification is a reminder to deliver. Late is set when it came due while
+// the server was not running and is sent after its time.
+type Notification struct {
+	TaskID   int64  `json:"task_id"`
+	Date     string `json:"date"`
+	Title    string `json:"title"`
+	Comment  string `json:"comment"`
+	Repeat   string `json:"repeat"`
+	RemindAt string `json:"remind_at"`
+	Late     bool   `json:"late"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+type LogNotifier struct {
+	Logger *slog.Logger
+}
+
+func (l *LogNotifier) Notify(ctx context.Context, n Notification) error {
+	l.Logger.InfoContext(ctx, "reminder", "task", n.TaskID, "title", n.Title, "date", n.Date, "comment", n.Comment, "late", n.Late)
+	return nil
+}
+
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (wh *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := wh.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type SMTPNotifier struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+func (s *SMTPNotifier) Notify(_ context.Context, n Notification) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host := s.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+
+	if err := smtp.SendMail(s.Addr, auth, s.From, s.To, s.message(n)); err != nil {
+		return fmt.Errorf("failed to send reminder email: %w", err)
+	}
+	return nil
+}
+
+func (s *SMTPNotifier) message(n Notification) []byte {
+	date := n.Date
+	if t, err := time.Parse("20060102", n.Date); err == nil {
+		date = t.Format("02.01.2006")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Напоминание: "+n.Title))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s\r\nДата: %s\r\n", n.Title, date)
+	if n.Late {
+		b.WriteString("Напоминание отправлено с опозданием: сервер не работал в назначенное время\r\n")
+	}
+	if n.Comment != "" {
+		fmt.Fprintf(&b, "\r\n%s\r\n", n.Comment)
+	}
+	return []byte(b.String())
+}
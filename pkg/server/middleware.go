@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/ElenaMask/go_final_project/pkg/logging"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "todo_http_requests_total",
+		Help: "HTTP requests by route pattern and status.",
+	}, []string{"route", "status"})
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "todo_http_request_duration_seconds",
+		Help:    "HTTP request latency by route pattern and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "status"})
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds IDs taken from clients, which end up in every log
+// record of the request.
+const maxRequestIDLen = 128
+
+// withRequestLog keeps the client's X-Request-ID or assigns a new one, echoes
+// it in the response and logs one line per request once it is served, with
+// the user the authentication middleware recorded. Only the path is logged:
+// query strings may carry the calendar feed token.
+func withRequestLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := logging.WithUser(logging.WithRequestID(r.Context(), id))
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		logger.LogAttrs(ctx, slog.LevelInfo, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("user", logging.User(ctx)),
+		)
+	})
+}
+
+// withMetrics counts requests by the mux pattern that served them, so that
+// the number of series does not depend on the paths clients ask for.
+func withMetrics(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w}
+		// The mux sets Pattern on the request it is given, and so does a
+		// nested one like /api/v1/. When none of the nested routes match,
+		// as for a method they do not allow, the pattern is cleared, and the
+		// request is counted under the outer pattern instead.
+		_, outer := mux.Handler(r)
+		mux.ServeHTTP(rec, r)
+		route := r.Pattern
+		if route == "" {
+			route = outer
+		}
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		status := strconv.Itoa(rec.status)
+		httpRequests.WithLabelValues(route, status).Inc()
+		httpDuration.WithLabelValues(route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ElenaMask/go_final_project/pkg/logging"
+)
+
+func TestRequestLog(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "json")
+	require.NoError(t, err)
+
+	handler := withRequestLog(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("id") {
+			logging.SetUser(r.Context(), "owner")
+		}
+		logger.ErrorContext(r.Context(), "error on getting task from database")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"Задача не найдена"}`))
+	}))
+
+	records := func() []map[string]any {
+		var list []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var rec map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &rec))
+			list = append(list, rec)
+		}
+		out.Reset()
+		return list
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/task?id=1", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, "abc-123", w.Header().Get(requestIDHeader))
+	recs := records()
+	require.Len(t, recs, 2)
+	assert.Equal(t, "abc-123", recs[0]["request_id"])
+	access := recs[1]
+	assert.Equal(t, "request", access["msg"])
+	assert.Equal(t, "abc-123", access["request_id"])
+	assert.Equal(t, http.MethodGet, access["method"])
+	assert.Equal(t, "/api/task", access["path"])
+	assert.EqualValues(t, http.StatusNotFound, access["status"])
+	assert.EqualValues(t, len(`{"error":"Задача не найдена"}`), access["bytes"])
+	assert.Equal(t, "owner", access["user"])
+	assert.Contains(t, access, "duration")
+
+	// Missing or malformed IDs are replaced.
+	for _, id := range []string{"", "bad id", strings.Repeat("x", maxRequestIDLen+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(requestIDHeader, id)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		got := w.Header().Get(requestIDHeader)
+		assert.NotEmpty(t, got)
+		assert.NotEqual(t, id, got)
+		recs := records()
+		assert.Equal(t, got, recs[0]["request_id"])
+		assert.Equal(t, got, recs[1]["request_id"])
+		assert.Equal(t, logging.Anonymous, recs[1]["user"])
+	}
+}
+
+func TestLoggingFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "text")
+	require.NoError(t, err)
+	logger.InfoContext(logging.WithRequestID(t.Context(), "abc"), "hello")
+	assert.Contains(t, out.String(), "msg=hello request_id=abc")
+
+	_, err = logging.New(&out, "xml")
+	assert.Error(t, err)
+}
+
+func TestRequestMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/task", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	v1 := http.NewServeMux()
+	v1.HandleFunc("GET /api/v1/tasks", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/api/v1/", v1)
+	handler := withMetrics(mux)
+
+	observations := func(route, status string) uint64 {
+		var m dto.Metric
+		require.NoError(t, httpDuration.WithLabelValues(route, status).(prometheus.Histogram).Write(&m))
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("/api/task", "404"))
+	beforeRoot := observations("/", "200")
+	for _, target := range []string{"/api/task?id=1", "/api/task?id=2", "/js/scripts.min.js", "/favicon.ico"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	assert.Equal(t, before+2, testutil.ToFloat64(httpRequests.WithLabelValues("/api/task", "404")))
+	assert.Equal(t, beforeRoot+2, observations("/", "200"))
+
+	// A method the nested mux does not allow is still attributed to it.
+	before = testutil.ToFloat64(httpRequests.WithLabelValues("/api/v1/", "405"))
+	beforeV1 := testutil.ToFloat64(httpRequests.WithLabelValues("GET /api/v1/tasks", "200"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/tasks", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/api/v1/tasks", nil))
+	assert.Equal(t, beforeV1+1, testutil.ToFloat64(httpRequests.WithLabelValues("GET /api/v1/tasks", "200")))
+	assert.Equal(t, before+1, testutil.ToFloat64(httpRequests.WithLabelValues("/api/v1/", "405")))
+	assert.Zero(t, testutil.ToFloat64(httpRequests.WithLabelValues("", "405")))
+}
@@ -2,36 +2,115 @@ package server
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 
+	scheduler "github.com/ElenaMask/go_final_project"
 	"github.com/ElenaMask/go_final_project/pkg/api"
+	"github.com/ElenaMask/go_final_project/pkg/config"
+	"github.com/ElenaMask/go_final_project/pkg/db"
 )
 
 type Server struct {
-	Logger     *log.Logger
+	Logger     *slog.Logger
 	HttpServer *http.Server
+	// AdminServer serves /metrics on its own port; it is nil when the metrics
+	// are served by HttpServer.
+	AdminServer *http.Server
 }
 
-func NewServer(port int, logger *log.Logger, webDir string) *Server {
+// NewServer builds the servers; with MetricsPort 0, /metrics is served on
+// Port. The web UI built into the binary is served unless WebDir is set.
+func NewServer(cfg *config.Config, logger *slog.Logger, store db.TaskStore) (*Server, error) {
+	web := scheduler.Web()
+	var files http.Handler
+	if cfg.WebDir != "" {
+		web = os.DirFS(cfg.WebDir)
+		files = devFiles(web)
+	} else {
+		static, err := newStaticFiles(web)
+		if err != nil {
+			return nil, err
+		}
+		files = static
+	}
+	h := api.New(store, web, cfg)
+
+	// Calendar feeds, imports, backups, reminders and time tracking keep their
+	// data in the SQLite database next to the tasks.
+	sqliteOnly := func(handler http.HandlerFunc) http.HandlerFunc {
+		if _, ok := store.(db.SQLiteStore); ok {
+			return handler
+		}
+		return api.SQLiteOnlyHandler
+	}
+
+	// With TODO_PASSWORD set, the API needs the token from /api/signin. The
+	// web files, health checks and the calendar feed, which has a token of
+	// its own, stay open.
+	auth := h.Auth
+
 	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.Dir(webDir)))
-	mux.HandleFunc("/api/nextdate", api.NextDateHandler)
-	mux.HandleFunc("/api/task", api.TaskHandler)
-	mux.HandleFunc("/api/task/done", api.DoneTaskHandler)
-	mux.HandleFunc("/api/tasks", api.TasksHandler)
-	addr := fmt.Sprintf(":%d", port)
-	httpServer := &http.Server{
-		Addr:         addr,
-		Handler:      mux,
-		ErrorLog:     logger,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  15 * time.Second,
+	mux.Handle("/", files)
+	mux.HandleFunc("/healthz", api.HealthzHandler)
+	mux.HandleFunc("/readyz", h.ReadyzHandler)
+	mux.HandleFunc("/api/nextdate", h.NextDateHandler)
+	mux.HandleFunc("/api/signin", h.SigninHandler)
+	mux.HandleFunc("/api/task", auth(h.TaskHandler))
+	mux.HandleFunc("/api/task/done", auth(h.DoneTaskHandler))
+	mux.HandleFunc("/api/task/quick", auth(h.QuickTaskHandler))
+	mux.HandleFunc("/api/tasks", auth(h.TasksHandler))
+	mux.HandleFunc("/api/agenda", auth(h.AgendaHandler))
+	// The v1 routes have a mux of their own: in mux, "/" would serve any
+	// method that a v1 route does not allow instead of answering 405.
+	v1 := http.NewServeMux()
+	v1.HandleFunc("GET /api/v1/tasks", h.TasksHandler)
+	v1.HandleFunc("POST /api/v1/tasks", h.CreateTaskV1Handler)
+	v1.HandleFunc("GET /api/v1/tasks/{id}", h.GetTaskV1Handler)
+	v1.HandleFunc("PUT /api/v1/tasks/{id}", h.UpdateTaskV1Handler)
+	v1.HandleFunc("DELETE /api/v1/tasks/{id}", h.DeleteTaskV1Handler)
+	v1.HandleFunc("POST /api/v1/tasks/{id}/done", h.DoneTaskV1Handler)
+	mux.Handle("/api/v1/", auth(v1.ServeHTTP))
+	mux.HandleFunc("/api/calendar.ics", sqliteOnly(h.CalendarHandler))
+	mux.HandleFunc("/api/calendar/token", auth(sqliteOnly(api.CalendarTokenHandler)))
+	mux.HandleFunc("/api/import/ics", auth(sqliteOnly(h.ImportICSHandler)))
+	mux.HandleFunc("/api/export.csv", auth(h.ExportCSVHandler))
+	mux.HandleFunc("/api/import.csv", auth(sqliteOnly(h.ImportCSVHandler)))
+	mux.HandleFunc("/api/backup", auth(sqliteOnly(h.BackupHandler)))
+	mux.HandleFunc("/api/restore", auth(sqliteOnly(api.RestoreHandler)))
+	mux.HandleFunc("/api/admin/snapshots", auth(sqliteOnly(h.SnapshotsHandler)))
+	mux.HandleFunc("/api/reminder", auth(sqliteOnly(h.ReminderHandler)))
+	mux.HandleFunc("/api/timer", auth(sqliteOnly(h.TimerHandler)))
+	mux.HandleFunc("/api/timer/start", auth(sqliteOnly(h.StartTimerHandler)))
+	mux.HandleFunc("/api/timer/stop", auth(sqliteOnly(h.StopTimerHandler)))
+	mux.HandleFunc("/api/time", auth(sqliteOnly(h.TimeHandler)))
+	mux.HandleFunc("/api/time/totals", auth(sqliteOnly(h.TimeTotalsHandler)))
+
+	var adminServer *http.Server
+	if cfg.MetricsPort == 0 {
+		mux.HandleFunc("/metrics", h.MetricsHandler)
+	} else {
+		adminMux := http.NewServeMux()
+		adminMux.HandleFunc("/metrics", h.MetricsHandler)
+		adminServer = newHTTPServer(cfg, cfg.MetricsPort, logger, adminMux)
 	}
+
 	return &Server{
-		Logger:     logger,
-		HttpServer: httpServer,
+		Logger:      logger,
+		HttpServer:  newHTTPServer(cfg, cfg.Port, logger, withRequestLog(logger, withMetrics(mux))),
+		AdminServer: adminServer,
+	}, nil
+}
+
+func newHTTPServer(cfg *config.Config, port int, logger *slog.Logger, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         fmt.Sprintf(":%d", port),
+		Handler:      handler,
+		ErrorLog:     slog.NewLogLogger(logger.Handler(), slog.LevelError),
+		ReadTimeout:  time.Duration(cfg.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(cfg.WriteTimeout) * time.Second,
+		IdleTimeout:  time.Duration(cfg.IdleTimeout) * time.Second,
 	}
 }
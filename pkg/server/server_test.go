@@ -0,0 +1,137 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ElenaMask/go_final_project/pkg/config"
+	"github.com/ElenaMask/go_final_project/pkg/db"
+	"github.com/ElenaMask/go_final_project/pkg/logging"
+)
+
+type doFunc func(method, target, body, token string) *httptest.ResponseRecorder
+
+// newPasswordServer starts a server with TODO_PASSWORD set and returns a
+// function that sends it requests with an optional token cookie.
+func newPasswordServer(t *testing.T) doFunc {
+	require.NoError(t, db.Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	cfg := config.Default()
+	cfg.Password = "secret"
+	srv, err := NewServer(cfg, slog.New(slog.DiscardHandler), db.SQLiteStore{})
+	require.NoError(t, err)
+	handler := srv.HttpServer.Handler
+
+	return func(method, target, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+}
+
+func signin(t *testing.T, do doFunc) string {
+	rec := do(http.MethodPost, "/api/signin", `{"password":"secret"}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp struct{ Token string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp.Token
+}
+
+func TestPasswordProtectsAPI(t *testing.T) {
+	do := newPasswordServer(t)
+
+	protected := []string{
+		"/api/task?id=1", "/api/tasks", "/api/agenda", "/api/v1/tasks",
+		"/api/calendar/token", "/api/export.csv", "/api/backup",
+		"/api/admin/snapshots", "/api/reminder?id=1", "/api/timer?id=1",
+		"/api/time?id=1", "/api/time/totals",
+	}
+	for _, target := range protected {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, target, "", "").Code, target)
+	}
+	for _, target := range []string{"/api/task/done?id=1", "/api/task/quick", "/api/import.csv", "/api/import/ics", "/api/restore", "/api/timer/start", "/api/timer/stop"} {
+		assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, target, "", "").Code, target)
+	}
+
+	// The web files, health checks, metrics, nextdate, sign-in and the
+	// calendar feed, which has a token of its own, need no sign-in.
+	open := []string{
+		"/", "/healthz", "/readyz", "/metrics",
+		"/api/nextdate?now=20261019&date=20261019&repeat=d+1", "/api/calendar.ics",
+	}
+	for _, target := range open {
+		assert.NotEqual(t, http.StatusUnauthorized, do(http.MethodGet, target, "", "").Code, target)
+	}
+
+	token := signin(t, do)
+	for _, target := range protected {
+		assert.NotEqual(t, http.StatusUnauthorized, do(http.MethodGet, target, "", token).Code, target)
+	}
+}
+
+func TestCalendarTokenNeedsPassword(t *testing.T) {
+	do := newPasswordServer(t)
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/calendar/token", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPost, "/api/calendar/token", "", "").Code)
+
+	rec := do(http.MethodGet, "/api/calendar/token", "", signin(t, do))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var feed struct{ Token string }
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &feed))
+
+	// The feed token opens the feed and nothing else, and only in the URL.
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/calendar.ics?token="+feed.Token, "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/calendar/token", "", feed.Token).Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/api/tasks", "", feed.Token).Code)
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/calendar.ics", "", feed.Token).Code)
+}
+
+func TestAccessLogUser(t *testing.T) {
+	require.NoError(t, db.Init(filepath.Join(t.TempDir(), "scheduler.db")))
+	cfg := config.Default()
+	cfg.Password = "secret"
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "json")
+	require.NoError(t, err)
+	srv, err := NewServer(cfg, logger, db.SQLiteStore{})
+	require.NoError(t, err)
+
+	users := func(token string) []any {
+		out.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/api/tasks", nil)
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		}
+		srv.HttpServer.Handler.ServeHTTP(httptest.NewRecorder(), req)
+		var list []any
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var rec map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &rec))
+			if rec["msg"] == "request" {
+				list = append(list, rec["user"])
+			}
+		}
+		return list
+	}
+
+	assert.Equal(t, []any{logging.Anonymous}, users(""))
+	token := signin(t, func(method, target, body, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.HttpServer.Handler.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	})
+	assert.Equal(t, []any{"owner"}, users(token))
+}
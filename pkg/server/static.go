@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// staticFiles serves the web UI from memory. Every file is read and
+// compressed once, when the server starts.
+type staticFiles map[string]*staticFile
+
+type staticFile struct {
+	contentType string
+	// version is the content hash that pages append to the URLs of js/ and
+	// css/ files as ?v=; empty for other files.
+	version string
+	// variants are ordered by preference: brotli, gzip, then the file as is.
+	variants []staticVariant
+}
+
+type staticVariant struct {
+	encoding string
+	etag     string
+	data     []byte
+}
+
+// assetRef matches the references to scripts and styles in the pages.
+var assetRef = regexp.MustCompile(`((?:src|href)=")/((?:js|css)/[^"?#]+)"`)
+
+func newStaticFiles(fsys fs.FS) (staticFiles, error) {
+	contents := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		contents[name], err = fs.ReadFile(fsys, name)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load web files: %w", err)
+	}
+
+	// Scripts and styles are referenced with their hash, so that they can be
+	// cached for good and a new version of one gets a new URL.
+	versions := map[string]string{}
+	for name, data := range contents {
+		if strings.HasPrefix(name, "js/") || strings.HasPrefix(name, "css/") {
+			versions[name] = contentHash(data)
+		}
+	}
+	for name, data := range contents {
+		if path.Ext(name) == ".html" {
+			contents[name] = assetRef.ReplaceAllFunc(data, func(ref []byte) []byte {
+				m := assetRef.FindSubmatch(ref)
+				v, ok := versions[string(m[2])]
+				if !ok {
+					return ref
+				}
+				return fmt.Appendf(nil, `%s/%s?v=%s"`, m[1], m[2], v)
+			})
+		}
+	}
+
+	files := staticFiles{}
+	for name, data := range contents {
+		if files[name], err = prepareStatic(name, data); err != nil {
+			return nil, fmt.Errorf("failed to load web files: %w", err)
+		}
+		files[name].version = versions[name]
+	}
+	return files, nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+func prepareStatic(name string, data []byte) (*staticFile, error) {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	// The ETag is derived from the content, so that it stays the same across
+	// restarts and differs between the encodings of the file.
+	etag := contentHash(data)
+	f := &staticFile{contentType: contentType}
+
+	br, err := compress(data, func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, brotli.BestCompression), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	gz, err := compress(data, func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Files that do not shrink, like images, are only served as is.
+	if len(br) < len(data) {
+		f.variants = append(f.variants, staticVariant{"br", `"` + etag + `-br"`, br})
+	}
+	if len(gz) < len(data) {
+		f.variants = append(f.variants, staticVariant{"gzip", `"` + etag + `-gz"`, gz})
+	}
+	f.variants = append(f.variants, staticVariant{"", `"` + etag + `"`, data})
+	return f, nil
+}
+
+func compress(data []byte, newWriter func(io.Writer) (io.WriteCloser, error)) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (files staticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+	f, ok := files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	v := f.variants[len(f.variants)-1]
+	for _, candidate := range f.variants {
+		if candidate.encoding == "" || acceptsEncoding(r.Header.Get("Accept-Encoding"), candidate.encoding) {
+			v = candidate
+			break
+		}
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", f.contentType)
+	// A script or style requested with its current hash never changes under
+	// that URL. Everything else, the pages with the hashes included, is
+	// revalidated by its ETag; an unchanged file costs a 304 without a body.
+	if f.version != "" && r.URL.Query().Get("v") == f.version {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	h.Set("ETag", v.etag)
+	if len(f.variants) > 1 {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if v.encoding != "" {
+		h.Set("Content-Encoding", v.encoding)
+	}
+	// ServeContent answers If-None-Match with 304 and handles ranges.
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(v.data))
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows coding,
+// by name or by "*", with a non-zero q-value.
+func acceptsEncoding(header, coding string) bool {
+	q, star := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if weight, err = strconv.ParseFloat(value, 64); err != nil {
+				weight = 0
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case coding:
+			q = weight
+		case "*":
+			star = weight
+		}
+	}
+	if q < 0 {
+		q = star
+	}
+	return q > 0
+}
+
+// devFiles serves the web UI from a directory as it is on disk, so that edits
+// show up on reload without restarting the server.
+func devFiles(fsys fs.FS) http.Handler {
+	files := http.FileServerFS(fsys)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		files.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticFiles(t *testing.T) {
+	script := strings.Repeat("console.log('планировщик');\n", 100)
+	files, err := newStaticFiles(fstest.MapFS{
+		"index.html":  {Data: []byte(`<html><script src="/js/app.js"></script>` + strings.Repeat("<p>задачи</p>", 50) + "</html>")},
+		"js/app.js":   {Data: []byte(script)},
+		"favicon.ico": {Data: []byte{0, 0, 1, 0}},
+	})
+	require.NoError(t, err)
+
+	get := func(target, acceptEncoding string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		files.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/js/app.js", "gzip, deflate, br")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"), "unversioned URLs must be revalidated")
+	assert.Contains(t, rec.Header().Get("Content-Type"), "javascript")
+	body, err := io.ReadAll(brotli.NewReader(rec.Body))
+	require.NoError(t, err)
+	assert.Equal(t, script, string(body))
+	brTag := rec.Header().Get("ETag")
+
+	rec = get("/js/app.js", "gzip, br;q=0")
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, script, string(body))
+	assert.NotEqual(t, brTag, rec.Header().Get("ETag"), "each encoding has its own ETag")
+
+	rec = get("/js/app.js", "identity, *;q=0")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, script, rec.Body.String())
+
+	rec = get("/js/app.js", "br", "If-None-Match", brTag)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+
+	rec = get("/", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	// The page refers to the script by its hash, and that URL is cached.
+	version := contentHash([]byte(script))
+	assert.Contains(t, rec.Body.String(), `<script src="/js/app.js?v=`+version+`"></script>`)
+	rec = get("/js/app.js?v="+version, "br")
+	assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+	rec = get("/js/app.js?v=0123", "br")
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"), "an outdated hash must be revalidated")
+
+	// A file that does not shrink is only served as is.
+	rec = get("/favicon.ico", "br, gzip")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Empty(t, rec.Header().Get("Vary"))
+	assert.True(t, bytes.Equal([]byte{0, 0, 1, 0}, rec.Body.Bytes()))
+
+	assert.Equal(t, http.StatusNotFound, get("/js/missing.js", "").Code)
+	assert.Equal(t, http.StatusNotFound, get("/js/../../etc/passwd", "").Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/index.html", nil)
+	rec = httptest.NewRecorder()
+	files.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, HEAD", rec.Header().Get("Allow"))
+}
@@ -0,0 +1,274 @@
+// Package snapshot keeps rotated online copies of the SQLite database and
+// restores the database file from them.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+const (
+	namePrefix = "scheduler-"
+	nameSuffix = ".db"
+	nameLayout = "20060102-150405"
+)
+
+// sqliteHeader starts every SQLite database file.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// mu serializes snapshots taken by the schedule and by the admin endpoint.
+var mu sync.Mutex
+
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Manager takes snapshots into Dir and keeps only the newest Keep of them;
+// Keep <= 0 keeps all. Only files named like snapshots are ever removed.
+type Manager struct {
+	Dir    string
+	Keep   int
+	Now    func() time.Time
+	Logger *slog.Logger
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+// Create takes a snapshot and rotates old ones. The copy is written under a
+// temporary name first, so an interrupted snapshot is never listed.
+func (m *Manager) Create(ctx context.Context) (*Snapshot, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup dir: %w", err)
+	}
+
+	now := m.now()
+	name := namePrefix + now.Format(nameLayout) + nameSuffix
+	path := filepath.Join(m.Dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("snapshot %s already exists", name)
+	}
+
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := db.Snapshot(ctx, tmp); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+	if err := m.rotate(); err != nil {
+		return nil, err
+	}
+	return &Snapshot{Name: name, Size: info.Size(), CreatedAt: now}, nil
+}
+
+// List returns the snapshots in Dir, newest first.
+func (m *Manager) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup dir: %w", err)
+	}
+
+	// Names carry the local time of the clock that took the snapshot.
+	loc := m.now().Location()
+	snapshots := make([]Snapshot, 0)
+	for _, e := range entries {
+		created, ok := parseName(e.Name(), loc)
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Name: e.Name(), Size: info.Size(), CreatedAt: created})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name > snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+func (m *Manager) rotate() error {
+	if m.Keep <= 0 {
+		return nil
+	}
+	snapshots, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots[min(m.Keep, len(snapshots)):] {
+		if err := os.Remove(filepath.Join(m.Dir, s.Name)); err != nil {
+			return fmt.Errorf("failed to remove old snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+func parseName(name string, loc *time.Location) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, namePrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, nameSuffix)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(nameLayout, stamp, loc)
+	return t, err == nil
+}
+
+// Run takes a snapshot every time the schedule fires until ctx is done.
+func (m *Manager) Run(ctx context.Context, schedule Schedule) {
+	for {
+		now := m.now()
+		timer := time.NewTimer(schedule.Next(now).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s, err := m.Create(ctx)
+		if err != nil {
+			m.Logger.Error("error when taking database snapshot", "error", err)
+			continue
+		}
+		m.Logger.Info("database snapshot saved", "name", s.Name, "bytes", s.Size)
+	}
+}
+
+// Schedule fires either daily at a time of day or at a fixed interval.
+type Schedule struct {
+	Every time.Duration
+	Hour  int
+	Min   int
+}
+
+// ParseSchedule accepts a daily time such as "03:00" or an interval such as
+// "6h" or "30m".
+func ParseSchedule(s string) (Schedule, error) {
+	if t, err := time.Parse("15:04", s); err == nil {
+		return Schedule{Hour: t.Hour(), Min: t.Minute()}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < time.Minute {
+		return Schedule{}, fmt.Errorf("invalid schedule %q, use HH:MM or an interval of at least 1m", s)
+	}
+	return Schedule{Every: d}, nil
+}
+
+// Next returns the first firing time after now, in the location of now.
+func (s Schedule) Next(now time.Time) time.Time {
+	if s.Every > 0 {
+		return now.Add(s.Every)
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), s.Hour, s.Min, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, s.Hour, s.Min, 0, 0, now.Location())
+	}
+	return next
+}
+
+// Restore replaces dbFile with a snapshot before the database is opened.
+// source is "latest", a snapshot name in dir or a path to a file. The
+// replaced database is kept next to it with the ".before-restore" suffix.
+// It returns the path of the restored snapshot.
+func Restore(dir, source, dbFile string) (string, error) {
+	path := source
+	switch {
+	case source == "latest":
+		snapshots, err := (&Manager{Dir: dir}).List()
+		if err != nil {
+			return "", err
+		}
+		if len(snapshots) == 0 {
+			return "", fmt.Errorf("no snapshots in %s", dir)
+		}
+		path = filepath.Join(dir, snapshots[0].Name)
+	case !strings.ContainsRune(source, filepath.Separator):
+		if _, err := os.Stat(source); err != nil {
+			path = filepath.Join(dir, source)
+		}
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer src.Close()
+
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(src, header); err != nil || !bytes.Equal(header, sqliteHeader) {
+		return "", fmt.Errorf("%s is not an SQLite database", path)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	tmp := dbFile + ".restore"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to create database file: %w", err)
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+
+	if _, err := os.Stat(dbFile); err == nil {
+		if err := os.Rename(dbFile, dbFile+".before-restore"); err != nil {
+			os.Remove(tmp)
+			return "", fmt.Errorf("failed to keep current database: %w", err)
+		}
+	}
+	// Journals of the replaced database would be applied to the snapshot.
+	// They go along with the kept copy: after a crash the write-ahead log may
+	// hold transactions that are not in the database file yet.
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Rename(dbFile+suffix, dbFile+".before-restore"+suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			os.Remove(dbFile + suffix)
+		}
+	}
+	if err := os.Rename(tmp, dbFile); err != nil {
+		return "", fmt.Errorf("failed to replace database: %w", err)
+	}
+	return path, nil
+}
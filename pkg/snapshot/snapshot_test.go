@@ -0,0 +1,107 @@
+package snapshot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ElenaMask/go_final_project/pkg/db"
+)
+
+func TestCreateRotateRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "scheduler.db")
+	require.NoError(t, db.Init(dbFile))
+
+	_, err := db.AddTask(t.Context(), &db.Task{Date: "20260101", Title: "До снимка"})
+	require.NoError(t, err)
+
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	m := &Manager{Dir: filepath.Join(dir, "backups"), Keep: 2, Now: func() time.Time { return now }}
+
+	// Unrelated files in the backup dir are never touched by rotation.
+	require.NoError(t, os.MkdirAll(m.Dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(m.Dir, "notes.txt"), []byte("x"), 0o644))
+
+	first, err := m.Create(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "scheduler-20261019-030000.db", first.Name)
+	assert.Positive(t, first.Size)
+
+	_, err = m.Create(t.Context())
+	assert.Error(t, err, "снимок с тем же именем")
+
+	_, err = db.AddTask(t.Context(), &db.Task{Date: "20260101", Title: "После снимка"})
+	require.NoError(t, err)
+	for range 2 {
+		now = now.Add(time.Hour)
+		_, err = m.Create(t.Context())
+		require.NoError(t, err)
+	}
+
+	snapshots, err := m.List()
+	require.NoError(t, err)
+	if assert.Len(t, snapshots, 2) {
+		assert.Equal(t, "scheduler-20261019-050000.db", snapshots[0].Name)
+		assert.Equal(t, "scheduler-20261019-040000.db", snapshots[1].Name)
+	}
+	assert.FileExists(t, filepath.Join(m.Dir, "notes.txt"))
+
+	_, err = Restore(m.Dir, "notes.txt", dbFile)
+	assert.Error(t, err)
+
+	path, err := Restore(m.Dir, "latest", dbFile)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(m.Dir, snapshots[0].Name), path)
+	assert.FileExists(t, dbFile+".before-restore")
+
+	require.NoError(t, db.Init(dbFile))
+	tasks, err := db.Tasks(t.Context(), db.Cursor{}, -1)
+	require.NoError(t, err)
+	assert.Len(t, tasks, 2)
+}
+
+func TestListInManagerLocation(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, db.Init(filepath.Join(dir, "scheduler.db")))
+
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+	now := time.Date(2026, 10, 19, 3, 0, 0, 0, moscow)
+	m := &Manager{Dir: filepath.Join(dir, "backups"), Now: func() time.Time { return now }}
+
+	s, err := m.Create(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "scheduler-20261019-030000.db", s.Name)
+
+	snapshots, err := m.List()
+	require.NoError(t, err)
+	if assert.Len(t, snapshots, 1) {
+		assert.True(t, now.Equal(snapshots[0].CreatedAt), "%s != %s", snapshots[0].CreatedAt, now)
+		assert.Equal(t, moscow, snapshots[0].CreatedAt.Location())
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	now := time.Date(2026, 10, 19, 12, 30, 0, 0, loc)
+
+	daily, err := ParseSchedule("03:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 10, 20, 3, 0, 0, 0, loc), daily.Next(now))
+	assert.Equal(t, time.Date(2026, 10, 20, 3, 0, 0, 0, loc), daily.Next(time.Date(2026, 10, 19, 3, 0, 0, 0, loc)))
+	assert.Equal(t, time.Date(2026, 10, 19, 3, 0, 0, 0, loc), daily.Next(time.Date(2026, 10, 19, 2, 59, 0, 0, loc)))
+
+	every, err := ParseSchedule("6h")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(6*time.Hour), every.Next(now))
+
+	for _, s := range []string{"", "25:00", "3", "10s", "daily"} {
+		_, err := ParseSchedule(s)
+		assert.Error(t, err, s)
+	}
+}
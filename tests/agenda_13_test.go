@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type agendaResp struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Occurrences []struct {
+		ID   string `json:"id"`
+		Date string `json:"date"`
+		Kind string `json:"kind"`
+	} `json:"occurrences"`
+	Truncated bool   `json:"truncated"`
+	Error     string `json:"error"`
+}
+
+func getAgenda(t *testing.T, params url.Values) agendaResp {
+	body, err := requestJSON("api/agenda?"+params.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var resp agendaResp
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+func TestAgenda(t *testing.T) {
+	base := time.Now().AddDate(4, 0, 0)
+	day := func(n int) string {
+		return base.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	ids := []string{
+		addTask(t, task{date: day(2), title: "Повестка разовая"}),
+		addTask(t, task{date: day(1), title: "Повестка каждые 3 дня", repeat: "d 3"}),
+		addTask(t, task{date: day(-5), title: "Повестка просрочена", repeat: "d 2"}),
+		addTask(t, task{date: day(30), title: "Повестка после диапазона", repeat: "d 1"}),
+	}
+
+	resp := getAgenda(t, url.Values{"from": {day(0)}, "to": {day(9)}, "now": {day(0)}})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, day(0), resp.From)
+	assert.Equal(t, day(9), resp.To)
+
+	got := make(map[string][]string)
+	for _, o := range resp.Occurrences {
+		got[o.ID] = append(got[o.ID], o.Date+" "+o.Kind)
+	}
+	assert.Equal(t, []string{day(2) + " real"}, got[ids[0]])
+	assert.Equal(t, []string{
+		day(1) + " real",
+		day(4) + " projected",
+		day(7) + " projected",
+	}, got[ids[1]])
+	assert.Equal(t, []string{
+		day(1) + " projected",
+		day(3) + " projected",
+		day(5) + " projected",
+		day(7) + " projected",
+		day(9) + " projected",
+	}, got[ids[2]])
+	assert.Empty(t, got[ids[3]])
+
+	for i := 1; i < len(resp.Occurrences); i++ {
+		assert.LessOrEqual(t, resp.Occurrences[i-1].Date, resp.Occurrences[i].Date)
+	}
+
+	for _, params := range []url.Values{
+		{"from": {day(0)}, "to": {day(400)}},
+		{"from": {day(9)}, "to": {day(0)}},
+		{"from": {"someday"}},
+	} {
+		assert.NotEmpty(t, getAgenda(t, params).Error, "ожидается ошибка для %v", params)
+	}
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
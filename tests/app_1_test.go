@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
@@ -55,6 +56,8 @@ func walkDir(path string, f func(fname string) error) error {
 	return nil
 }
 
+var assetVersion = regexp.MustCompile(`\?v=[0-9a-f]+`)
+
 func TestApp(t *testing.T) {
 	cmp := func(fname string) error {
 		fbody, err := os.ReadFile(fname)
@@ -65,6 +68,10 @@ func TestApp(t *testing.T) {
 		if err != nil {
 			return err
 		}
+		// Pages refer to scripts and styles with their content hash.
+		if filepath.Ext(fname) == ".html" {
+			body = assetVersion.ReplaceAll(body, nil)
+		}
 		assert.Equal(t, len(fbody), len(body), `сервер возвращает для %s данные другого размера`, fname)
 		return nil
 	}
@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func restore(t *testing.T, mode string, data []byte) map[string]any {
+	resp, err := http.Post(getURL("api/restore?mode="+mode), "application/json", strings.NewReader(string(data)))
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m
+}
+
+func TestBackupRestore(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 2).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "Резервная копия", comment: "проверка", repeat: "d 1"})
+	ret, err := postJSON("api/reminder", map[string]any{"task_id": id, "offset_days": 1, "time": "08:30"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+	ret, err = postJSON("api/time", map[string]any{"task_id": id, "minutes": 15}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+
+	dump, err := getBody("api/backup")
+	assert.NoError(t, err)
+	var backup struct {
+		Format  string                      `json:"format"`
+		Version int                         `json:"version"`
+		Tables  map[string][]map[string]any `json:"tables"`
+	}
+	assert.NoError(t, json.Unmarshal(dump, &backup))
+	assert.Equal(t, "go_final_project-backup", backup.Format)
+	assert.Equal(t, 2, backup.Version)
+	found := false
+	for _, row := range backup.Tables["tasks"] {
+		if row["title"] == "Резервная копия" {
+			found = true
+			assert.Equal(t, date, row["date"])
+		}
+	}
+	assert.True(t, found)
+	assert.NotEmpty(t, backup.Tables["reminders"])
+	assert.NotEmpty(t, backup.Tables["time_entries"])
+
+	before, err := count(db)
+	assert.NoError(t, err)
+
+	for mode, data := range map[string]string{
+		"replace":   `{"format":"go_final_project-backup","version":99,"tables":{}}`,
+		"merge":     `{"format":"other","version":1,"tables":{}}`,
+		"overwrite": string(dump),
+	} {
+		assert.NotEmpty(t, restore(t, mode, []byte(data))["error"], "ожидается ошибка для %s: %s", mode, data)
+	}
+	bad := `{"format":"go_final_project-backup","version":1,"tables":{"tasks":[` +
+		`{"id":900000001,"date":"` + date + `","title":"Частичное восстановление","comment":"","repeat":""},` +
+		`{"id":900000002,"date":"bad","title":"x","comment":"","repeat":""}]}}`
+	assert.NotEmpty(t, restore(t, "merge", []byte(bad))["error"])
+	after, err := count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after, "ошибка в файле не должна применяться частично")
+
+	merged := restore(t, "merge", dump)
+	if assert.Empty(t, merged["error"]) {
+		assert.Equal(t, float64(before), merged["restored"].(map[string]any)["tasks"])
+	}
+	after, err = count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*before, after)
+
+	replaced := restore(t, "replace", dump)
+	assert.Empty(t, replaced["error"])
+	after, err = count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "Резервная копия", task["title"])
+	body, err := requestJSON("api/reminder?task_id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"08:30"`)
+
+	_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+}
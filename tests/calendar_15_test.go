@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func calendarToken(t *testing.T, method string) string {
+	body, err := requestJSON("api/calendar/token", nil, method)
+	assert.NoError(t, err)
+	var m map[string]string
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.Contains(t, m["url"], "/api/calendar.ics?token="+m["token"])
+	return m["token"]
+}
+
+// calendarEntries returns the unfolded content lines of every entry by UID.
+func calendarEntries(t *testing.T, params url.Values) map[string][]string {
+	body, err := getBody("api/calendar.ics?" + params.Encode())
+	assert.NoError(t, err)
+	feed := string(body)
+	if !assert.True(t, strings.HasPrefix(feed, "BEGIN:VCALENDAR\r\n"), feed) {
+		return nil
+	}
+	for _, line := range strings.Split(feed, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, "строка длиннее 75 октетов")
+	}
+
+	entries := make(map[string][]string)
+	var entry []string
+	for _, line := range strings.Split(strings.ReplaceAll(feed, "\r\n ", ""), "\r\n") {
+		switch {
+		case line == "BEGIN:VEVENT" || line == "BEGIN:VTODO":
+			entry = []string{line}
+		case line == "END:VEVENT" || line == "END:VTODO":
+			for _, l := range entry {
+				if uid, ok := strings.CutPrefix(l, "UID:"); ok {
+					entries[uid] = entry
+				}
+			}
+			entry = nil
+		case entry != nil:
+			entry = append(entry, line)
+		}
+	}
+	return entries
+}
+
+func TestCalendarFeed(t *testing.T) {
+	if !FullNextDate {
+		return
+	}
+	date := time.Now().AddDate(0, 0, 3).Format(`20060102`)
+	longTitle := strings.Repeat("Очень длинное название задачи ", 5)
+
+	ids := []string{
+		addTask(t, task{date: date, title: "Созвон", comment: "план: a, b; c\nвторая строка"}),
+		addTask(t, task{date: date, title: "Полив", repeat: "d 3"}),
+		addTask(t, task{date: date, title: "Тренировка", repeat: "w 1,3,5"}),
+		addTask(t, task{date: date, title: "Отчёт", repeat: "m 1,-1 2,6"}),
+		addTask(t, task{date: "20280229", title: "Високосный день", repeat: "y"}),
+		addTask(t, task{date: date, title: longTitle}),
+	}
+	uid := func(i int) string {
+		return "task-" + ids[i] + "@go-final-project"
+	}
+
+	token := calendarToken(t, http.MethodGet)
+	assert.Equal(t, token, calendarToken(t, http.MethodGet), "токен должен сохраняться")
+
+	body, err := getBody("api/calendar.ics")
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"error"`)
+	body, err = getBody("api/calendar.ics?token=wrong")
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"error"`)
+
+	events := calendarEntries(t, url.Values{"token": {token}})
+	assert.Contains(t, events[uid(0)], "DTSTART;VALUE=DATE:"+date)
+	assert.Contains(t, events[uid(0)], `DESCRIPTION:план: a\, b\; c\nвторая строка`)
+	assert.Contains(t, events[uid(1)], "RRULE:FREQ=DAILY;INTERVAL=3")
+	assert.Contains(t, events[uid(2)], "RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR")
+	assert.Contains(t, events[uid(3)], "RRULE:FREQ=MONTHLY;BYMONTHDAY=1,-1;BYMONTH=2,6")
+	assert.Contains(t, events[uid(5)], "SUMMARY:"+longTitle)
+	for _, line := range events[uid(4)] {
+		assert.False(t, strings.HasPrefix(line, "RRULE"), "29 февраля нельзя выразить через RRULE")
+	}
+
+	todos := calendarEntries(t, url.Values{"token": {token}, "component": {"vtodo"}})
+	assert.Len(t, todos, len(events))
+	assert.Contains(t, todos[uid(1)], "RRULE:FREQ=DAILY;INTERVAL=3")
+	assert.Contains(t, todos[uid(1)], "STATUS:NEEDS-ACTION")
+
+	_, err = postJSON("api/task/done?id="+ids[1], nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Contains(t, calendarEntries(t, url.Values{"token": {token}}), uid(1), "UID не должен меняться")
+
+	rotated := calendarToken(t, http.MethodPost)
+	assert.NotEqual(t, token, rotated)
+	body, err = getBody("api/calendar.ics?token=" + token)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"error"`)
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
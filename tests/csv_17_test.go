@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type csvImportResp struct {
+	Created int    `json:"created"`
+	Updated int    `json:"updated"`
+	Error   string `json:"error"`
+	Rows    []struct {
+		Row   int    `json:"row"`
+		Error string `json:"error"`
+	} `json:"rows"`
+}
+
+func importCSV(t *testing.T, mode, data string) csvImportResp {
+	resp, err := http.Post(getURL("api/import.csv?mode="+mode), "text/csv", strings.NewReader(data))
+	if !assert.NoError(t, err) {
+		return csvImportResp{}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var ret csvImportResp
+	assert.NoError(t, json.Unmarshal(body, &ret))
+	return ret
+}
+
+func exportCSV(t *testing.T) map[string][]string {
+	body, err := getBody("api/export.csv")
+	assert.NoError(t, err)
+	data, ok := strings.CutPrefix(string(body), "\uFEFF")
+	assert.True(t, ok, "ожидается BOM")
+
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	assert.NoError(t, err)
+	rows := make(map[string][]string)
+	if assert.NotEmpty(t, records) {
+		assert.Equal(t, []string{"id", "date", "title", "comment", "repeat"}, records[0])
+		for _, record := range records[1:] {
+			rows[record[0]] = record
+		}
+	}
+	return rows
+}
+
+func TestCSVImportExport(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	date := time.Now().AddDate(0, 0, 4).Format(`20060102`)
+	id := addTask(t, task{date: date, title: "CSV экспорт", comment: "с запятой, \"кавычками\"\nи строкой", repeat: "d 5"})
+
+	rows := exportCSV(t)
+	assert.Equal(t, []string{id, date, "CSV экспорт", "с запятой, \"кавычками\"\nи строкой", "d 5"}, rows[id])
+
+	before, err := count(db)
+	assert.NoError(t, err)
+
+	bad := "id,date,title,comment,repeat\n" +
+		",," + "CSV верная строка,,\n" +
+		"," + date + ",CSV плохое правило,,x 1\n" +
+		"," + date + ",,без заголовка,\n" +
+		",31.02.2026,CSV плохая дата,,\n"
+	ret := importCSV(t, "insert", bad)
+	assert.NotEmpty(t, ret.Error)
+	if assert.Len(t, ret.Rows, 3) {
+		assert.Equal(t, 3, ret.Rows[0].Row)
+		assert.Equal(t, 4, ret.Rows[1].Row)
+		assert.Equal(t, 5, ret.Rows[2].Row)
+	}
+	after, err := count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after, "файл с ошибками не должен применяться частично")
+
+	upsert := "title;id;date;repeat\n" +
+		"CSV изменённая;" + id + ";" + time.Now().AddDate(0, 0, 6).Format(`02.01.2006`) + ";d 2\n" +
+		"CSV новая;;" + date + ";\n"
+	ret = importCSV(t, "upsert", upsert)
+	assert.Empty(t, ret.Error)
+	assert.Equal(t, 1, ret.Updated)
+	assert.Equal(t, 1, ret.Created)
+
+	task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "CSV изменённая", task["title"])
+	assert.Equal(t, time.Now().AddDate(0, 0, 6).Format(`20060102`), task["date"])
+	assert.Equal(t, "d 2", task["repeat"])
+	assert.Equal(t, "с запятой, \"кавычками\"\nи строкой", task["comment"], "отсутствующий столбец не меняет поле")
+
+	ret = importCSV(t, "upsert", "id,title\n999999999,CSV нет такой задачи\n")
+	assert.Len(t, ret.Rows, 1)
+
+	ret = importCSV(t, "insert", "id,title,date\n"+id+",CSV копия,"+date+"\n")
+	assert.Equal(t, 1, ret.Created, "в режиме insert id не учитывается")
+
+	ids := []string{id}
+	for rowID, row := range exportCSV(t) {
+		if row[2] == "CSV новая" || row[2] == "CSV копия" {
+			ids = append(ids, rowID)
+		}
+	}
+	assert.Len(t, ids, 3)
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCSVFormulas(t *testing.T) {
+	date := time.Now().AddDate(0, 0, 4).Format(`20060102`)
+	formula := addTask(t, task{date: date, title: "=HYPERLINK(\"http://example.com\")", comment: "-1 день"})
+	rows := exportCSV(t)
+	assert.Equal(t, "'=HYPERLINK(\"http://example.com\")", rows[formula][2], "формулы экранируются")
+	assert.Equal(t, "'-1 день", rows[formula][3])
+	ret := importCSV(t, "upsert", "id,title,comment\n"+formula+",'+CSV формула,'-1 день\n")
+	assert.Equal(t, 1, ret.Updated)
+	got, err := postJSON("api/task?id="+formula, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "+CSV формула", got["title"], "кавычка экспорта снимается при импорте")
+	assert.Equal(t, "-1 день", got["comment"])
+
+	// A title that really starts with a quote survives a round trip.
+	quoted := addTask(t, task{date: date, title: "'=не формула", comment: "'цитата'"})
+	rows = exportCSV(t)
+	assert.Equal(t, "''=не формула", rows[quoted][2])
+	assert.Equal(t, "''цитата'", rows[quoted][3])
+	ret = importCSV(t, "upsert", "id,title,comment\n"+quoted+","+rows[quoted][2]+","+rows[quoted][3]+"\n")
+	assert.Equal(t, 1, ret.Updated)
+	got, err = postJSON("api/task?id="+quoted, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "'=не формула", got["title"])
+	assert.Equal(t, "'цитата'", got["comment"])
+	_, err = postJSON("api/task?id="+quoted, nil, http.MethodDelete)
+	assert.NoError(t, err)
+
+	assert.Contains(t, importCSV(t, "insert", "").Error, "файл пуст")
+	assert.Contains(t, importCSV(t, "insert", "title,title\nCSV,CSV\n").Error, "повторяется")
+	assert.Contains(t, importCSV(t, "insert", "date,comment\n,\n").Error, "нет столбца title")
+
+	_, err = postJSON("api/task?id="+formula, nil, http.MethodDelete)
+	assert.NoError(t, err)
+}
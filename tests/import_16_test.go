@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type importItem struct {
+	UID    string   `json:"uid"`
+	ID     string   `json:"id"`
+	Action string   `json:"action"`
+	Title  string   `json:"title"`
+	Date   string   `json:"date"`
+	Repeat string   `json:"repeat"`
+	Issues []string `json:"issues"`
+}
+
+type importResp struct {
+	DryRun  bool         `json:"dry_run"`
+	Created int          `json:"created"`
+	Updated int          `json:"updated"`
+	Skipped int          `json:"skipped"`
+	Items   []importItem `json:"items"`
+	Error   string       `json:"error"`
+}
+
+func importICS(t *testing.T, query, data string) importResp {
+	resp, err := http.Post(getURL("api/import/ics"+query), "text/calendar", strings.NewReader(data))
+	if !assert.NoError(t, err) {
+		return importResp{}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var ret importResp
+	assert.NoError(t, json.Unmarshal(body, &ret))
+	return ret
+}
+
+func TestImportICS(t *testing.T) {
+	if !FullNextDate {
+		return
+	}
+	now := time.Now()
+	future := now.AddDate(0, 0, 10).Format(`20060102`)
+	past := now.AddDate(0, 0, -10).Format(`20060102`)
+
+	data := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Test//Import//EN",
+		"BEGIN:VEVENT",
+		"UID:import-weekly@test",
+		"SUMMARY:Импорт планёрки",
+		`DESCRIPTION:первая строка\nвторая\, с запятой`,
+		"DTSTART;VALUE=DATE:" + future,
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE",
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:import-monthly@test",
+		"SUMMARY:Импорт отчёта с очень длинным назва",
+		" нием",
+		"DUE:" + future + "T090000Z",
+		"RRULE:FREQ=MONTHLY;BYDAY=2MO",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"UID:import-daily@test",
+		"SUMMARY:Импорт полива",
+		"DTSTART;TZID=Europe/Moscow:" + future + "T100000",
+		"RRULE:FREQ=DAILY;INTERVAL=2;COUNT=5",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:import-past@test",
+		"SUMMARY:Импорт прошлого события",
+		"DTSTART;VALUE=DATE:" + past,
+		"END:VEVENT",
+		"BEGIN:VTODO",
+		"UID:import-done@test",
+		"SUMMARY:Импорт выполненной задачи",
+		"STATUS:COMPLETED",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"SUMMARY:Импорт без UID",
+		"DTSTART;VALUE=DATE:" + future,
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	report := importICS(t, "?dry_run=1", data)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 4, report.Created)
+	assert.Equal(t, 2, report.Skipped)
+	if assert.Len(t, report.Items, 6) {
+		assert.Equal(t, "w 1,3", report.Items[0].Repeat)
+		assert.Equal(t, "Импорт отчёта с очень длинным названием", report.Items[1].Title)
+		assert.NotEmpty(t, report.Items[1].Issues, "BYDAY=2MO нельзя выразить точно")
+		assert.Equal(t, "d 2", report.Items[2].Repeat)
+		assert.NotEmpty(t, report.Items[2].Issues, "COUNT не поддерживается")
+		assert.Equal(t, "skip", report.Items[3].Action)
+		assert.Equal(t, "skip", report.Items[4].Action)
+		assert.NotEmpty(t, report.Items[5].Issues, "нет UID")
+		for _, item := range report.Items {
+			assert.Empty(t, item.ID, "dry_run не должен сохранять задачи")
+		}
+	}
+
+	report = importICS(t, "", data)
+	assert.Equal(t, 4, report.Created)
+	ids := make([]string, 0)
+	for _, item := range report.Items {
+		if item.ID != "" {
+			ids = append(ids, item.ID)
+		}
+	}
+	if assert.Len(t, ids, 4) {
+		task, err := postJSON("api/task?id="+ids[0], nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, "Импорт планёрки", task["title"])
+		assert.Equal(t, "первая строка\nвторая, с запятой", task["comment"])
+		assert.Equal(t, future, task["date"])
+		assert.Equal(t, "w 1,3", task["repeat"])
+	}
+
+	again := importICS(t, "", data)
+	assert.Equal(t, 3, again.Updated)
+	assert.Equal(t, 1, again.Created, "запись без UID создаётся заново")
+	for i, item := range again.Items[:3] {
+		assert.Equal(t, "update", item.Action)
+		if i < len(ids) {
+			assert.Equal(t, ids[i], item.ID)
+		}
+	}
+	ids = append(ids, again.Items[5].ID)
+
+	assert.NotEmpty(t, importICS(t, "", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n").Error)
+	assert.NotEmpty(t, importICS(t, "", "not a calendar").Error)
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tasksPage struct {
+	Tasks      []map[string]string `json:"tasks"`
+	NextCursor string              `json:"next_cursor"`
+	Total      *int                `json:"total"`
+}
+
+func getTasksPage(t *testing.T, params url.Values) tasksPage {
+	body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var page tasksPage
+	assert.NoError(t, json.Unmarshal(body, &page))
+	return page
+}
+
+func TestTasksPagination(t *testing.T) {
+	if !Search {
+		return
+	}
+	date := time.Now().AddDate(1, 2, 3)
+
+	ids := make(map[string]bool)
+	for i := 0; i < 7; i++ {
+		ids[addTask(t, task{
+			date:  date.Format(`20060102`),
+			title: "Страница",
+		})] = true
+	}
+
+	params := url.Values{}
+	params.Set("search", date.Format(`02.01.2006`))
+	params.Set("limit", "3")
+
+	seen := make(map[string]bool)
+	pages := 0
+	for {
+		page := getTasksPage(t, params)
+		pages++
+		if assert.NotNil(t, page.Total) {
+			assert.Equal(t, 7, *page.Total)
+		}
+		assert.LessOrEqual(t, len(page.Tasks), 3)
+		for _, task := range page.Tasks {
+			assert.False(t, seen[task["id"]], "задача %s встречается на двух страницах", task["id"])
+			seen[task["id"]] = true
+		}
+		if page.NextCursor == "" || pages > 5 {
+			break
+		}
+		params.Set("cursor", page.NextCursor)
+	}
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, ids, seen)
+
+	params.Set("cursor", "не курсор")
+	body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	assert.NotEmpty(t, m["error"])
+
+	for id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchQueryLanguage(t *testing.T) {
+	if !Search {
+		return
+	}
+	now := time.Now()
+	day := func(n int) string {
+		return now.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	ids := []string{
+		addTask(t, task{date: day(2), title: "Deploy backend", comment: "после ревью", repeat: "d 7"}),
+		addTask(t, task{date: day(40), title: "Deploy frontend", comment: "draft релиза"}),
+		addTask(t, task{date: day(3), title: "Созвон по деплою", comment: "deploy checklist", repeat: "w 1,3"}),
+	}
+
+	search := func(q string) []string {
+		page := getTasksPage(t, url.Values{"search": {q}, "limit": {"500"}})
+		found := make([]string, 0)
+		for _, task := range page.Tasks {
+			for _, id := range ids {
+				if task["id"] == id {
+					found = append(found, id)
+				}
+			}
+		}
+		return found
+	}
+
+	assert.ElementsMatch(t, ids, search("deploy"))
+	assert.ElementsMatch(t, ids[:2], search("title:deploy"))
+	assert.ElementsMatch(t, ids[2:], search("comment:deploy"))
+	assert.ElementsMatch(t, ids[:1], search("title:deploy repeat:yes"))
+	assert.ElementsMatch(t, ids[1:2], search("deploy repeat:no"))
+	assert.ElementsMatch(t, ids[2:], search("deploy repeat:w"))
+	assert.ElementsMatch(t, ids[:1], search("title:deploy -comment:draft before:"+day(30)))
+	assert.ElementsMatch(t, ids[1:2], search("deploy after:"+now.AddDate(0, 0, 30).Format(`02.01.2006`)))
+	assert.ElementsMatch(t, ids, search("deploy after:today"))
+	assert.ElementsMatch(t, ids[1:], search(`-"после ревью" deploy`))
+	assert.ElementsMatch(t, ids[:1], search("on:"+day(2)))
+	assert.ElementsMatch(t, ids[2:], search(`"по деплою"`))
+	assert.ElementsMatch(t, ids[2:], search("title:созв* -repeat:d"))
+	assert.Empty(t, search("deploy 16:00 Встреча:"))
+
+	for _, tc := range []struct {
+		query    string
+		position int
+		token    string
+	}{
+		{"deploy titel:x", 8, "titel:"},
+		{"deploy before:someday", 15, "someday"},
+		{`title:"deploy`, 7, `"deploy`},
+		{"repeat:often", 8, "often"},
+		{"deploy - x", 8, "-"},
+		{"title: deploy", 1, "title:"},
+	} {
+		body, err := requestJSON("api/tasks?search="+url.QueryEscape(tc.query), nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.NotEmpty(t, m["error"], "ожидается ошибка для %q", tc.query)
+		assert.Equal(t, float64(tc.position), m["position"], "позиция ошибки для %q", tc.query)
+		assert.Equal(t, tc.token, m["token"], "токен ошибки для %q", tc.query)
+	}
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
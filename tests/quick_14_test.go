@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuickAdd(t *testing.T) {
+	if !FullNextDate {
+		return
+	}
+	// 19.10.2026 - понедельник.
+	const now = "20261019"
+
+	for _, tc := range []struct {
+		text   string
+		title  string
+		date   string
+		repeat string
+	}{
+		{"Pay rent every month on the 1st", "Pay rent", "20261101", "m 1"},
+		{"Стендап каждый будний день", "Стендап", "20261019", "w 1,2,3,4,5"},
+		{"Call mom tomorrow", "Call mom", "20261020", ""},
+		{"Позвонить маме послезавтра", "Позвонить маме", "20261021", ""},
+		{"Gym every 3 days", "Gym", "20261019", "d 3"},
+		{"Отчёт каждые две недели", "Отчёт", "20261019", "d 14"},
+		{"Team sync every monday and thursday", "Team sync", "20261019", "w 1,4"},
+		{"Уборка по субботам", "Уборка", "20261024", "w 6"},
+		{"Review draft on friday", "Review draft", "20261023", ""},
+		{"Зарплата 5 и 20 числа каждого месяца", "Зарплата", "20261020", "m 5,20"},
+		{"Налоги в последний день месяца", "Налоги", "20261031", "m -1"},
+		{"Anna's birthday every year on march 5", "Anna's birthday", "20270305", "y"},
+		{"Встреча через 2 недели", "Встреча", "20261102", ""},
+		{"Deadline 15.11.2026", "Deadline", "20261115", ""},
+		{"Отпуск 3 января", "Отпуск", "20270103", ""},
+		{"Полить цветы через день", "Полить цветы", "20261019", "d 2"},
+		{"Meeting at 16:00", "Meeting at 16:00", "20261019", ""},
+		{"Buy 2.5 kg apples", "Buy 2.5 kg apples", "20261019", ""},
+		{"Release version 1.2", "Release version 1.2", "20261019", ""},
+		{"Сдать отчёт на 2.11", "Сдать отчёт", "20261102", ""},
+		{"Отчёт 05.03", "Отчёт", "20270305", ""},
+		{"День рождения 29 февраля", "День рождения", "20280229", ""},
+		{"Квартальный отчёт every 3 months", "Квартальный отчёт", "20261019", "m 19 1,4,7,10"},
+		{"Налоги каждые 6 месяцев 15 числа", "Налоги", "20270415", "m 15 4,10"},
+		{"Daily standup every day", "standup", "20261019", "d 1"},
+		{"Уборка по субботам каждую субботу", "Уборка", "20261024", "w 6"},
+		{"Отчёт every 3 months on the 30th", "Отчёт", "20261030", "m 30 1,4,7,10"},
+	} {
+		body, err := requestJSON("api/task/quick?dry_run=1&now="+now, map[string]any{"text": tc.text}, http.MethodPost)
+		assert.NoError(t, err)
+		var m map[string]string
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.Empty(t, m["error"], "текст %q", tc.text)
+		assert.Empty(t, m["id"], "dry_run не должен сохранять задачу")
+		assert.Equal(t, tc.title, m["title"], "название для %q", tc.text)
+		assert.Equal(t, tc.date, m["date"], "дата для %q", tc.text)
+		assert.Equal(t, tc.repeat, m["repeat"], "правило для %q", tc.text)
+	}
+
+	for _, text := range []string{"", "every day", "каждые 100 недель",
+		"Отпуск 31 апреля", "Встреча 30.02", "Отчёт каждые 5 месяцев", "Renew passport every 2 years",
+		"Stand-up daily every week", "Report every 3 months on the 31st", "Отчёт каждые 6 месяцев 31 числа"} {
+		body, err := requestJSON("api/task/quick?dry_run=1&now="+now, map[string]any{"text": text}, http.MethodPost)
+		assert.NoError(t, err)
+		var m map[string]any
+		assert.NoError(t, json.Unmarshal(body, &m))
+		assert.NotEmpty(t, m["error"], "ожидается ошибка для %q", text)
+	}
+
+	ret, err := postJSON("api/task/quick", map[string]any{"text": "Быстрая задача каждые 2 дня"}, http.MethodPost)
+	assert.NoError(t, err)
+	id, _ := ret["id"].(string)
+	if assert.NotEmpty(t, id) {
+		task, err := postJSON("api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, "Быстрая задача", task["title"])
+		assert.Equal(t, "d 2", task["repeat"])
+
+		_, err = postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
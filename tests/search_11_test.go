@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFullTextSearch(t *testing.T) {
+	if !Search {
+		return
+	}
+	date := time.Now().AddDate(0, 0, 5).Format(`20060102`)
+
+	ids := []string{
+		addTask(t, task{date: date, title: "Деплой прода", comment: "выкатить релиз <b>2.0</b>"}),
+		addTask(t, task{date: date, title: "Годовой отчёт", comment: "отправить бухгалтеру"}),
+		addTask(t, task{date: date, title: "Релиз мобильного приложения", comment: ""}),
+	}
+
+	search := func(q string) []map[string]string {
+		return getTasksPage(t, url.Values{"search": {q}}).Tasks
+	}
+
+	found := search("деплой")
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, ids[0], found[0]["id"])
+		assert.Equal(t, "<mark>Деплой</mark> прода", found[0]["highlight"])
+	}
+
+	assert.Len(t, search("депл*"), 1)
+	assert.Len(t, search("еплой"), 0, "поиск не должен находить части слов")
+	assert.Len(t, search(`"годовой отчёт"`), 1)
+	assert.Len(t, search(`"отчёт годовой"`), 0)
+	assert.Len(t, search(`NOT ( "`), 0)
+
+	found = search("релиз")
+	if assert.Len(t, found, 2) {
+		assert.Equal(t, ids[2], found[0]["id"], "совпадение в заголовке должно быть выше")
+		assert.Equal(t, ids[0], found[1]["id"])
+		assert.Equal(t, "выкатить <mark>релиз</mark> &lt;b&gt;2.0&lt;/b&gt;", found[1]["snippet"])
+	}
+
+	page := getTasksPage(t, url.Values{"search": {"релиз"}, "limit": {"1"}})
+	if assert.Len(t, page.Tasks, 1) && assert.NotEmpty(t, page.NextCursor) {
+		next := getTasksPage(t, url.Values{"search": {"релиз"}, "limit": {"1"}, "cursor": {page.NextCursor}})
+		if assert.Len(t, next.Tasks, 1) {
+			assert.Equal(t, ids[0], next.Tasks[0]["id"])
+		}
+		assert.Empty(t, next.NextCursor)
+	}
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, search("деплой"), 0)
+}
+
+func TestSearchCaseFolding(t *testing.T) {
+	if !Search {
+		return
+	}
+	date := time.Now().AddDate(0, 0, 5).Format(`20060102`)
+
+	ids := []string{
+		addTask(t, task{date: date, title: "Квартальный Отчёт", comment: "Ёлка в офисе"}),
+		addTask(t, task{date: date, title: "Release Notes", comment: "Café menu"}),
+	}
+
+	search := func(q string) []map[string]string {
+		return getTasksPage(t, url.Values{"search": {q}}).Tasks
+	}
+
+	for _, q := range []string{"отчёт", "отчет", "ОТЧЕТ", "ОтЧёТ", "квартал*", "елка", "ЁЛКА"} {
+		found := search(q)
+		if assert.Len(t, found, 1, "запрос %q", q) {
+			assert.Equal(t, ids[0], found[0]["id"])
+		}
+	}
+	for _, q := range []string{"release", "RELEASE notes", "cafe", "CAFÉ"} {
+		found := search(q)
+		if assert.Len(t, found, 1, "запрос %q", q) {
+			assert.Equal(t, ids[1], found[0]["id"])
+		}
+	}
+
+	found := search("отчет")
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "Квартальный <mark>Отчёт</mark>", found[0]["highlight"])
+	}
+
+	_, err := postJSON("api/task", map[string]any{
+		"id":    ids[0],
+		"date":  date,
+		"title": "Квартальная смета",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Len(t, search("отчет"), 0)
+	assert.Len(t, search("смета"), 1)
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
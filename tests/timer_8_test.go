@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeTracking(t *testing.T) {
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Обслуживание сервера",
+		repeat: "d 7",
+	})
+
+	ret, err := postJSON("api/timer/start?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["id"])
+
+	ret, err = postJSON("api/timer/start?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"], "второй таймер не должен запускаться")
+
+	ret, err = postJSON("api/timer/stop", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret["error"])
+
+	ret, err = postJSON("api/time", map[string]any{
+		"task_id": id,
+		"minutes": 90,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["id"])
+
+	ret, err = postJSON("api/task/done?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+
+	ret, err = postJSON("api/time", map[string]any{
+		"task_id": id,
+		"minutes": 30,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["id"])
+
+	body, err := requestJSON("api/time/totals?task_id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	var totals struct {
+		Tasks []struct {
+			TaskID      string `json:"task_id"`
+			Duration    int64  `json:"duration"`
+			Occurrences []struct {
+				Date     string `json:"date"`
+				Duration int64  `json:"duration"`
+			} `json:"occurrences"`
+		} `json:"tasks"`
+		Total int64 `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &totals))
+	assert.Len(t, totals.Tasks, 1)
+	if len(totals.Tasks) == 1 {
+		occ := totals.Tasks[0].Occurrences
+		assert.Len(t, occ, 2, "время должно делиться между двумя повторениями задачи")
+		if len(occ) == 2 {
+			assert.Equal(t, now.Format(`20060102`), occ[0].Date)
+			assert.Equal(t, int64(90*60), occ[0].Duration)
+			assert.Equal(t, now.AddDate(0, 0, 7).Format(`20060102`), occ[1].Date)
+			assert.Equal(t, int64(30*60), occ[1].Duration)
+		}
+	}
+	assert.Equal(t, int64(120*60), totals.Total)
+
+	ret, err = postJSON(fmt.Sprintf("api/task?id=%s", id), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Empty(t, ret)
+}
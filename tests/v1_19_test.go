@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestV1(t *testing.T, method, path string, values map[string]any) (*http.Response, map[string]any) {
+	var data []byte
+	if values != nil {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(path), bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	var m map[string]any
+	if resp.Header.Get("Content-Type") == "application/json; charset=UTF-8" {
+		assert.NoError(t, json.Unmarshal(body, &m))
+	}
+	return resp, m
+}
+
+func TestTasksV1(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	resp, ret := requestV1(t, http.MethodPost, "api/v1/tasks", map[string]any{
+		"date":  today,
+		"title": "Задача через v1",
+	})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	id, _ := ret["id"].(string)
+	assert.NotEmpty(t, id)
+	assert.Equal(t, "/api/v1/tasks/"+id, resp.Header.Get("Location"))
+
+	resp, ret = requestV1(t, http.MethodGet, "api/v1/tasks/"+id, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Задача через v1", ret["title"])
+
+	resp, ret = requestV1(t, http.MethodPut, "api/v1/tasks/"+id, map[string]any{
+		"date":   today,
+		"title":  "Задача через v1, изменённая",
+		"repeat": "d 2",
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, ret["error"])
+	resp, _ = requestV1(t, http.MethodPut, "api/v1/tasks/"+id, map[string]any{
+		"id":    "1" + id,
+		"title": "Чужой идентификатор",
+	})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = requestV1(t, http.MethodPost, "api/v1/tasks/"+id+"/done", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var date string
+	assert.NoError(t, db.Get(&date, `SELECT date FROM scheduler WHERE id=?`, id))
+	assert.Equal(t, time.Now().AddDate(0, 0, 2).Format(`20060102`), date)
+
+	resp, _ = requestV1(t, http.MethodDelete, "api/v1/tasks/"+id, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, ret = requestV1(t, http.MethodGet, "api/v1/tasks/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.NotEmpty(t, ret["error"])
+	resp, _ = requestV1(t, http.MethodDelete, "api/v1/tasks/"+id, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	for _, bad := range []string{"abc", "0", "-1", "1.5"} {
+		resp, ret = requestV1(t, http.MethodGet, "api/v1/tasks/"+bad, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, bad)
+		assert.NotEmpty(t, ret["error"], bad)
+	}
+
+	resp, ret = requestV1(t, http.MethodGet, "api/v1/tasks", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, ret, "tasks")
+
+	for _, c := range []struct{ method, path, allow string }{
+		{http.MethodPatch, "api/v1/tasks/1", "DELETE, GET, HEAD, PUT"},
+		{http.MethodGet, "api/v1/tasks/1/done", "POST"},
+		{http.MethodDelete, "api/v1/tasks", "GET, HEAD, POST"},
+	} {
+		resp, _ = requestV1(t, c.method, c.path, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, c.method+" "+c.path)
+		assert.Equal(t, c.allow, resp.Header.Get("Allow"), c.method+" "+c.path)
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTasksViews(t *testing.T) {
+	base := time.Now().AddDate(3, 0, 0)
+	day := func(n int) string {
+		return base.AddDate(0, 0, n).Format(`20060102`)
+	}
+
+	ids := make([]string, 0)
+	for _, n := range []int{0, 1, 3, 10} {
+		ids = append(ids, addTask(t, task{
+			date:  day(n),
+			title: "Вид " + day(n),
+		}))
+	}
+
+	count := func(params url.Values) int {
+		page := getTasksPage(t, params)
+		return len(page.Tasks)
+	}
+	has := func(params url.Values, id string) bool {
+		for _, task := range getTasksPage(t, params).Tasks {
+			if task["id"] == id {
+				return true
+			}
+		}
+		return false
+	}
+
+	assert.Equal(t, 3, count(url.Values{"from": {day(0)}, "to": {day(3)}}))
+	assert.Equal(t, 2, count(url.Values{"from": {day(1)}, "to": {base.AddDate(0, 0, 3).Format(`02.01.2006`)}}))
+	assert.Equal(t, 1, count(url.Values{"view": {"today"}, "now": {day(0)}}))
+	assert.Equal(t, 3, count(url.Values{"view": {"week"}, "now": {day(0)}}))
+	assert.Equal(t, 3, count(url.Values{"view": {"upcoming"}, "now": {day(0)}}))
+
+	overdue := url.Values{"view": {"overdue"}, "now": {day(1)}, "limit": {"500"}}
+	assert.True(t, has(overdue, ids[0]))
+	assert.False(t, has(overdue, ids[1]))
+
+	for _, params := range []url.Values{
+		{"view": {"someday"}},
+		{"view": {"today"}, "from": {day(0)}},
+		{"from": {day(3)}, "to": {day(0)}},
+		{"search": {"Вид"}, "from": {day(0)}},
+	} {
+		body, err := requestJSON("api/tasks?"+params.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), `"error"`, "ожидается ошибка для %v", params)
+	}
+
+	for _, id := range ids {
+		_, err := postJSON("api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,19 @@
+// Package scheduler holds the files that are built into the server binary.
+package scheduler
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed web
+var files embed.FS
+
+// Web returns the web UI, with index.html at the root.
+func Web() fs.FS {
+	web, err := fs.Sub(files, "web")
+	if err != nil {
+		panic(err)
+	}
+	return web
+}